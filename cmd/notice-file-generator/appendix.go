@@ -0,0 +1,170 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// licenseTextsHeading starts the appendix holding each distinct license text
+// once, when licenseAppendix is enabled.
+const licenseTextsHeading = "LICENSE TEXTS:"
+
+// licenseTextsDir is the folder of the appendix texts, next to the stanzas.
+// GenerateFileName never produces a dot, so it cannot clash with a stanza.
+const licenseTextsDir = ".license-texts"
+
+var (
+	// regexpLicenseTextReference matches the reference of a stanza to an
+	// appendix text, ie. "[MIT-3c2f9a1b]".
+	regexpLicenseTextReference = regexp.MustCompile(`(?m)^\* LICENSE TEXT: see \[([A-Za-z0-9-]+)\]`)
+	regexpBlankLines           = regexp.MustCompile(`\n{3,}`)
+)
+
+// maxTitleLines bounds the first paragraph read as the title of a license,
+// ie. "MIT License", before its copyright lines.
+const maxTitleLines = 3
+
+// SplitLicenseText separates the copyright lines of a license text, which are
+// specific to the dependency, from the terms shared with other dependencies.
+// Only the copyright lines heading the text are separated: once a paragraph
+// of terms starts, the text is kept as is.
+func SplitLicenseText(text string) (terms string, copyrights []string) {
+	var lines []string
+	header, title := true, true
+	for _, paragraph := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		paragraphLines := strings.Split(paragraph, "\n")
+		if header {
+			var others []string
+			found := false
+			for _, line := range paragraphLines {
+				if _, ok := parseCopyright(line); ok {
+					copyrights = append(copyrights, strings.TrimSpace(line))
+					found = true
+					continue
+				}
+				others = append(others, line)
+			}
+			switch {
+			case found:
+				paragraphLines = others
+			case strings.TrimSpace(paragraph) == "":
+				continue
+			case !title || len(paragraphLines) > maxTitleLines:
+				header = false
+			}
+			title = false
+		}
+		for _, line := range paragraphLines {
+			lines = append(lines, strings.TrimRight(line, " \t"))
+		}
+		lines = append(lines, "")
+	}
+	terms = regexpBlankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	return strings.TrimSpace(terms), copyrights
+}
+
+// LicenseTextAnchor names the terms of a license after their license and a
+// hash of their text with whitespace collapsed, so the same terms get the same
+// anchor whatever their formatting and in every run.
+func LicenseTextAnchor(terms string) string {
+	sum := sha256.Sum256([]byte(regexpWhitespace.ReplaceAllString(terms, " ")))
+	id := IdentifyLicense(terms)
+	if id == "" {
+		id = "LICENSE"
+	}
+	return GenerateFileName(id) + "-" + hex.EncodeToString(sum[:4])
+}
+
+// licenseTextsPath returns the folder of the appendix texts of a notice folder.
+func licenseTextsPath(dir string) string {
+	return filepath.Join(dir, licenseTextsDir)
+}
+
+// WriteLicenseText stores the terms of a license for the appendix and returns
+// their anchor. Dependencies sharing the terms are generated concurrently, so
+// the file is replaced atomically.
+func WriteLicenseText(config *Config, terms string) (string, error) {
+	anchor := LicenseTextAnchor(terms)
+	dir := licenseTextsPath(config.NoticeWorkPath())
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(dir, anchor+".*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.WriteString(terms + "\n"); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err = tmp.Close(); err != nil {
+		return "", err
+	}
+	return anchor, os.Rename(tmp.Name(), filepath.Join(dir, anchor))
+}
+
+// licenseTextReference returns the part of a stanza replacing its license
+// text in appendix mode: the copyright lines and the appendix anchor.
+func (d *Dependency) licenseTextReference(config *Config) (string, error) {
+	terms, copyrights := SplitLicenseText(d.LicenseText)
+	if len(copyrights) == 0 {
+		// Holders found in the source headers
+		for _, c := range d.Copyrights {
+			copyrights = append(copyrights, c.String())
+		}
+	}
+	if terms == "" {
+		return strings.Join(copyrights, "\n"), nil
+	}
+	anchor, err := WriteLicenseText(config, terms)
+	if err != nil {
+		return "", err
+	}
+	reference := fmt.Sprintf("* LICENSE TEXT: see [%s] in %s", anchor, strings.TrimSuffix(licenseTextsHeading, ":"))
+	if len(copyrights) == 0 {
+		return reference, nil
+	}
+	return strings.Join(copyrights, "\n") + "\n\n" + reference, nil
+}
+
+// licenseTextAppendix returns the appendix texts referenced by the stanzas,
+// sorted by anchor. The texts of stanzas reused from the previous NOTICE.txt
+// are read from the notice folder.
+func licenseTextAppendix(config *Config, stanzas []string) []string {
+	referenced := make(map[string]bool)
+	for _, stanza := range stanzas {
+		for _, m := range regexpLicenseTextReference.FindAllStringSubmatch(stanza, -1) {
+			referenced[m[1]] = true
+		}
+	}
+	anchors := make([]string, 0, len(referenced))
+	for anchor := range referenced {
+		anchors = append(anchors, anchor)
+	}
+	sort.Strings(anchors)
+
+	var texts []string
+	for _, anchor := range anchors {
+		var text []byte
+		var err error
+		for _, dir := range []string{config.NoticeWorkPath(), config.NoticeDirPath()} {
+			if text, err = os.ReadFile(filepath.Join(licenseTextsPath(dir), anchor)); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			log.Printf("License text %s referenced in NOTICE.txt not found", anchor)
+			continue
+		}
+		texts = append(texts, fmt.Sprintf("### [%s]\n\n%s\n\n", anchor, strings.TrimSpace(string(text))))
+	}
+	return texts
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitLicenseText(t *testing.T) {
+	terms, copyrights := SplitLicenseText("MIT License\r\n\r\nCopyright (c) 2020 Jane Doe\r\nCopyright (c) 2021 Acme Corp\r\n\r\n\r\nPermission is hereby granted   \r\n")
+	assert.Equal(t, "MIT License\n\nPermission is hereby granted", terms)
+	assert.Equal(t, []string{"Copyright (c) 2020 Jane Doe", "Copyright (c) 2021 Acme Corp"}, copyrights)
+
+	// Only the copyright lines tell the texts apart
+	other, _ := SplitLicenseText("MIT License\n\nCopyright 2015 John Smith\n\nPermission is hereby granted")
+	assert.Equal(t, LicenseTextAnchor(terms), LicenseTextAnchor(other))
+	assert.Equal(t, LicenseTextAnchor("Permission  is\nhereby granted"), LicenseTextAnchor("Permission is hereby granted"))
+	assert.NotEqual(t, LicenseTextAnchor(terms), LicenseTextAnchor("ISC License"))
+	assert.Regexp(t, `^LICENSE-[0-9a-f]{8}$`, LicenseTextAnchor("Custom terms"))
+
+	// Copyright lines within the terms are part of them
+	apache, ok := StandardLicenseText("Apache-2.0")
+	require.True(t, ok)
+	terms, copyrights = SplitLicenseText("Copyright 2019 Acme Corp\n\n" + apache)
+	assert.Equal(t, []string{"Copyright 2019 Acme Corp"}, copyrights)
+	assert.Contains(t, terms, "(c) You must retain, in the Source form of any Derivative Works")
+	terms, copyrights = SplitLicenseText("ISC License\n\nPermission to use, copy, modify\nand distribute this software.\n\nCopyright 2019 Acme Corp")
+	assert.Empty(t, copyrights)
+	assert.True(t, strings.HasSuffix(terms, "Copyright 2019 Acme Corp"))
+}
+
+func TestLicenseAppendix(t *testing.T) {
+	config := &Config{Path: t.TempDir(), Title: "Title", Report: &Report{}, LicenseAppendix: true}
+	require.NoError(t, CreateNoticeDir(config))
+	deps := []Dependency{
+		{Name: "alpha", License: "MIT", LicenseText: mitLicense, DependencyType: ManualDep},
+		{Name: "beta", License: "MIT", LicenseText: strings.Replace(mitLicense, "2020 Jane Doe", "2018 John Smith", 1), DependencyType: ManualDep},
+		{Name: "gamma", License: "Custom", LicenseText: "Do what you want.", DependencyType: ManualDep},
+	}
+	for i := range deps {
+		require.NoError(t, deps[i].Generate(context.Background(), config))
+	}
+	require.NoError(t, UpdateNotice(config, deps))
+
+	notice, err := os.ReadFile(config.NoticeFilePath())
+	require.NoError(t, err)
+	mit := LicenseTextAnchor("Permission is hereby granted, free of charge, to any person obtaining a copy")
+	custom := LicenseTextAnchor("Do what you want.")
+	assert.Contains(t, string(notice), "## alpha\n\nThis product contains 'alpha' by Jane Doe.\n\n* LICENSE: MIT\n\nCopyright (c) 2020 Jane Doe\n\n* LICENSE TEXT: see ["+mit+"] in LICENSE TEXTS\n\n")
+	assert.Contains(t, string(notice), "Copyright (c) 2018 John Smith\n\n* LICENSE TEXT: see ["+mit+"] in LICENSE TEXTS\n\n")
+	assert.Contains(t, string(notice), "--------\n\nLICENSE TEXTS:\n--------\n\n")
+	assert.Equal(t, 1, strings.Count(string(notice), "Permission is hereby granted"))
+	assert.Contains(t, string(notice), "### ["+custom+"]\n\nDo what you want.\n\n")
+
+	// The texts of reused stanzas are recovered from NOTICE.txt
+	require.NoError(t, SplitExistingNotice(config))
+	stanza, err := os.ReadFile(filepath.Join(config.NoticeDirPath(), "gamma"))
+	require.NoError(t, err)
+	assert.Equal(t, "## gamma\n\nThis product contains 'gamma'.\n\n* LICENSE: Custom\n\n* LICENSE TEXT: see ["+custom+"] in LICENSE TEXTS\n\n", string(stanza))
+	text, err := os.ReadFile(filepath.Join(config.NoticeDirPath(), licenseTextsDir, mit))
+	require.NoError(t, err)
+	assert.Equal(t, "Permission is hereby granted, free of charge, to any person obtaining a copy", strings.TrimSpace(string(text)))
+
+	require.NoError(t, os.RemoveAll(config.NoticeWorkPath()))
+	require.NoError(t, CreateNoticeDir(config))
+	for _, d := range deps {
+		require.NoError(t, d.ReuseExistingNotice(config))
+	}
+	require.NoError(t, UpdateNotice(config, deps))
+	regenerated, err := os.ReadFile(config.NoticeFilePath())
+	require.NoError(t, err)
+	assert.Equal(t, string(notice), string(regenerated))
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+const defaultCacheTTL = 24 * time.Hour
+
+type cacheKeyContext struct{}
+
+// CacheKey is the ecosystem, name and version of the dependency a request is
+// made for. Responses are stored under it, so that a new version of a
+// dependency does not reuse the metadata fetched for the previous one.
+type CacheKey struct {
+	Ecosystem string
+	Name      string
+	Version   string
+	// credential is the hash of the credentials of the request, so that the
+	// responses to a token are never served to another token or without one
+	credential string
+}
+
+// WithCacheKey attaches the dependency a request is made for to ctx.
+func WithCacheKey(ctx context.Context, ecosystem, name, version string) context.Context {
+	return context.WithValue(ctx, cacheKeyContext{}, CacheKey{Ecosystem: ecosystem, Name: name, Version: version})
+}
+
+func cacheKeyFrom(ctx context.Context) CacheKey {
+	key, _ := ctx.Value(cacheKeyContext{}).(CacheKey)
+	return key
+}
+
+type cacheRevalidateContext struct{}
+
+// WithCacheRevalidation has the requests made with ctx revalidate their cached
+// response whatever its age, ie. for the refresh of a stanza.
+func WithCacheRevalidation(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheRevalidateContext{}, true)
+}
+
+func cacheRevalidationFrom(ctx context.Context) bool {
+	revalidate, _ := ctx.Value(cacheRevalidateContext{}).(bool)
+	return revalidate
+}
+
+func (k CacheKey) dir() string {
+	ecosystem, name, version := k.Ecosystem, k.Name, k.Version
+	if ecosystem == "" {
+		ecosystem = "shared"
+	}
+	if name == "" {
+		name = "_"
+	}
+	if version == "" {
+		version = "_"
+	}
+	return filepath.Join(ecosystem, GenerateFileName(name), GenerateFileName(version))
+}
+
+// cacheEntry is the metadata of a cached response. The body is stored
+// separately under the hash of its content, so identical license texts of
+// different dependencies are stored once.
+type cacheEntry struct {
+	URL          string    `json:"url"`
+	StatusCode   int       `json:"statusCode"`
+	ContentType  string    `json:"contentType,omitempty"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	Blob         string    `json:"blob"`
+	FetchedAt    time.Time `json:"fetchedAt"`
+}
+
+// Cache stores HTTP responses on disk between runs.
+type Cache struct {
+	Dir     string
+	TTL     time.Duration
+	Refresh bool
+	// Offline serves every cached entry, whatever its age
+	Offline bool
+
+	hits        atomic.Int64
+	revalidated atomic.Int64
+	misses      atomic.Int64
+}
+
+// DefaultCacheDir returns the notice-file-generator folder of the user cache
+// directory.
+func DefaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "notice-file-generator")
+	}
+	return filepath.Join(dir, "notice-file-generator")
+}
+
+// credentialHash returns the hash of the Authorization header of a request,
+// or an empty string for anonymous requests.
+func credentialHash(req *http.Request) string {
+	authorization := req.Header.Get("Authorization")
+	if authorization == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(authorization))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *Cache) entryPath(key CacheKey, url string) string {
+	sum := sha256.Sum256([]byte(url))
+	if key.credential != "" {
+		sum = sha256.Sum256([]byte(key.credential + " " + url))
+	}
+	return filepath.Join(c.Dir, key.dir(), hex.EncodeToString(sum[:16])+".json")
+}
+
+func (c *Cache) blobPath(blob string) string {
+	return filepath.Join(c.Dir, "blobs", blob[:2], blob)
+}
+
+func (c *Cache) load(key CacheKey, url string) (*cacheEntry, []byte) {
+	data, err := os.ReadFile(c.entryPath(key, url))
+	if err != nil {
+		return nil, nil
+	}
+	var entry cacheEntry
+	if err = json.Unmarshal(data, &entry); err != nil || entry.URL != url {
+		return nil, nil
+	}
+	body, err := os.ReadFile(c.blobPath(entry.Blob))
+	if err != nil {
+		return nil, nil
+	}
+	return &entry, body
+}
+
+func writeFileAtomic(name string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+func (c *Cache) store(key CacheKey, entry *cacheEntry, body []byte) {
+	sum := sha256.Sum256(body)
+	entry.Blob = hex.EncodeToString(sum[:])
+	if _, err := os.Stat(c.blobPath(entry.Blob)); os.IsNotExist(err) {
+		if err = writeFileAtomic(c.blobPath(entry.Blob), body); err != nil {
+			log.Printf("Error while writing cache blob %v", err)
+			return
+		}
+	}
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return
+	}
+	if err = writeFileAtomic(c.entryPath(key, entry.URL), data); err != nil {
+		log.Printf("Error while writing cache entry %v", err)
+	}
+}
+
+// LogStatistics logs the cache hits and misses of the run.
+func (c *Cache) LogStatistics() {
+	log.Printf("Metadata cache %s: %d hits, %d revalidated, %d misses", c.Dir, c.hits.Load(), c.revalidated.Load(), c.misses.Load())
+}
+
+func cachedResponse(req *http.Request, entry *cacheEntry, body []byte) *http.Response {
+	header := http.Header{}
+	if entry.ContentType != "" {
+		header.Set("Content-Type", entry.ContentType)
+	}
+	if entry.ETag != "" {
+		header.Set("ETag", entry.ETag)
+	}
+	return &http.Response{
+		Status:        http.StatusText(entry.StatusCode),
+		StatusCode:    entry.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// cacheable responses include 404s, as missing license file candidates would
+// otherwise be requested again on every run.
+func cacheable(code int) bool {
+	return code == http.StatusOK || code == http.StatusNotFound
+}
+
+// CacheTransport serves GET requests from the cache while they are younger
+// than the TTL, and revalidates older entries with conditional requests.
+type CacheTransport struct {
+	Base  http.RoundTripper
+	Cache *Cache
+}
+
+func (t *CacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || t.Cache == nil {
+		return t.Base.RoundTrip(req)
+	}
+
+	key := cacheKeyFrom(req.Context())
+	key.credential = credentialHash(req)
+	url := req.URL.String()
+	entry, body := t.Cache.load(key, url)
+
+	revalidate := t.Cache.Refresh || cacheRevalidationFrom(req.Context())
+	if entry != nil && (t.Cache.Offline || (!revalidate && time.Since(entry.FetchedAt) < t.Cache.TTL)) {
+		t.Cache.hits.Add(1)
+		return cachedResponse(req, entry, body), nil
+	}
+
+	r := req
+	if entry != nil && (entry.ETag != "" || entry.LastModified != "") {
+		r = req.Clone(req.Context())
+		if entry.ETag != "" {
+			r.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			r.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := t.Base.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		resp.Body.Close()
+		t.Cache.revalidated.Add(1)
+		entry.FetchedAt = time.Now()
+		t.Cache.store(key, entry, body)
+		return cachedResponse(req, entry, body), nil
+	}
+
+	t.Cache.misses.Add(1)
+	if !cacheable(resp.StatusCode) {
+		return resp, nil
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	t.Cache.store(key, &cacheEntry{
+		URL:          url,
+		StatusCode:   resp.StatusCode,
+		ContentType:  resp.Header.Get("Content-Type"),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		FetchedAt:    time.Now(),
+	}, data)
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	return resp, nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func useCache(t *testing.T, cache *Cache) {
+	useHTTPClient(t, &http.Client{Transport: &CacheTransport{Base: http.DefaultTransport, Cache: cache}})
+}
+
+func TestCacheTransport(t *testing.T) {
+	calls, conditional := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			conditional++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte("MIT License"))
+	}))
+	defer server.Close()
+
+	cache := &Cache{Dir: t.TempDir(), TTL: time.Hour}
+	useCache(t, cache)
+	ctx := WithCacheKey(context.Background(), "npm", "react", "18.2.0")
+
+	data, err := HTTPGet(ctx, server.URL+"/LICENSE")
+	assert.NoError(t, err)
+	assert.Equal(t, "MIT License", data)
+
+	data, err = HTTPGet(ctx, server.URL+"/LICENSE")
+	assert.NoError(t, err)
+	assert.Equal(t, "MIT License", data)
+	assert.Equal(t, 1, calls)
+
+	_, err = HTTPGet(ctx, server.URL+"/missing")
+	assert.True(t, IsNotFound(err))
+	_, err = HTTPGet(ctx, server.URL+"/missing")
+	assert.True(t, IsNotFound(err))
+	assert.Equal(t, 2, calls)
+
+	// Another version of the dependency does not share the entries
+	data, err = HTTPGet(WithCacheKey(context.Background(), "npm", "react", "19.0.0"), server.URL+"/LICENSE")
+	assert.NoError(t, err)
+	assert.Equal(t, "MIT License", data)
+	assert.Equal(t, 3, calls)
+
+	// Identical bodies are stored once
+	blobs, err := os.ReadDir(filepath.Join(cache.Dir, "blobs"))
+	require.NoError(t, err)
+	assert.Len(t, blobs, 2)
+
+	// The refresh of a stanza revalidates the entries of its dependency
+	data, err = HTTPGet(WithCacheRevalidation(ctx), server.URL+"/LICENSE")
+	assert.NoError(t, err)
+	assert.Equal(t, "MIT License", data)
+	assert.Equal(t, 4, calls)
+	assert.Equal(t, 1, conditional)
+
+	cache.Refresh = true
+	data, err = HTTPGet(ctx, server.URL+"/LICENSE")
+	assert.NoError(t, err)
+	assert.Equal(t, "MIT License", data)
+	assert.Equal(t, 5, calls)
+	assert.Equal(t, 2, conditional)
+
+	assert.Equal(t, int64(2), cache.hits.Load())
+	assert.Equal(t, int64(2), cache.revalidated.Load())
+	assert.Equal(t, int64(3), cache.misses.Load())
+}
+
+func TestCacheTransportCredentials(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get("Authorization") != "token secret" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("Private License"))
+	}))
+	defer server.Close()
+
+	cache := &Cache{Dir: t.TempDir(), TTL: time.Hour}
+	transport := &CacheTransport{Base: http.DefaultTransport, Cache: cache}
+	get := func(authorization string) int {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/LICENSE", nil)
+		require.NoError(t, err)
+		req = req.WithContext(WithCacheKey(context.Background(), "go", "private/mod", "v1.0.0"))
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		resp, err := transport.RoundTrip(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusOK, get("token secret"))
+	assert.Equal(t, http.StatusOK, get("token secret"))
+	assert.Equal(t, 1, calls)
+
+	// The response to a token is served neither without it nor to another one
+	assert.Equal(t, http.StatusNotFound, get(""))
+	assert.Equal(t, http.StatusNotFound, get("token other"))
+	assert.Equal(t, 3, calls)
+}
+
+func TestCacheTransportExpiredEntry(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get("If-Modified-Since") != "" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	cache := &Cache{Dir: t.TempDir(), TTL: 0}
+	useCache(t, cache)
+
+	for i := 0; i < 2; i++ {
+		data, err := HTTPGet(context.Background(), server.URL)
+		assert.NoError(t, err)
+		assert.Equal(t, "content", data)
+	}
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, int64(1), cache.revalidated.Load())
+}
@@ -4,27 +4,163 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	Title                  string   `yaml:"title"`
-	Copyright              string   `yaml:"copyright"`
-	Description            string   `yaml:"description"`
-	Reviewers              []string `yaml:"reviewers"`
-	Search                 []string `yaml:"search"`
-	IncludeDevDependencies bool     `yaml:"includeDevDependencies"`
-	AdditionalDependencies []string `yaml:"additionalDependencies"`
-	IgnoreDependencies     []string `yaml:"ignoreDependencies"`
-	Name                   string   `yaml:"-"`
-	Path                   string   `yaml:"-"`
-	GHToken                string   `yaml:"-"`
-	GoFiles                []string `yaml:"-"`
-	JSFIles                []string `yaml:"-"`
+	Title                  string                        `yaml:"title"`
+	Copyright              string                        `yaml:"copyright"`
+	Description            string                        `yaml:"description"`
+	Reviewers              []string                      `yaml:"reviewers"`
+	Search                 []string                      `yaml:"search"`
+	IncludeDevDependencies bool                          `yaml:"includeDevDependencies"`
+	AdditionalDependencies []AdditionalDependency        `yaml:"additionalDependencies"`
+	Overrides              map[string]DependencyOverride `yaml:"overrides"`
+	IgnoreDependencies     []IgnoreRule                  `yaml:"ignoreDependencies"`
+	ContainerImages        []string                      `yaml:"containerImages"`
+	DockerBuildArgs        map[string]string             `yaml:"dockerBuildArgs"`
+	ScanVendored           bool                          `yaml:"scanVendored"`
+	VendoredDirectories    []string                      `yaml:"vendoredDirectories"`
+	Hosts                  map[string]HostConfig         `yaml:"hosts"`
+	LicensePreference      []string                      `yaml:"licensePreference"`
+	LicenseAppendix        bool                          `yaml:"licenseAppendix"`
+	LicenseWrapWidth       int                           `yaml:"licenseWrapWidth"`
+	ShowVersions           bool                          `yaml:"showVersions"`
+	DisplayNames           string                        `yaml:"displayNames"`
+	RefreshPolicy          RefreshPolicy                 `yaml:"refreshPolicy"`
+	RefreshAfterDays       int                           `yaml:"refreshAfterDays"`
+	Name                   string                        `yaml:"-"`
+	Path                   string                        `yaml:"-"`
+	GHToken                string                        `yaml:"-"`
+	GHEnterpriseURL        string                        `yaml:"-"`
+	GHEnterpriseUploadURL  string                        `yaml:"-"`
+	GHEnterpriseToken      string                        `yaml:"-"`
+	GitHub                 *GitHub                       `yaml:"-"`
+	Concurrency            int                           `yaml:"-"`
+	Timeout                time.Duration                 `yaml:"-"`
+	RequestTimeout         time.Duration                 `yaml:"-"`
+	CacheDir               string                        `yaml:"-"`
+	CacheTTL               time.Duration                 `yaml:"-"`
+	Refresh                bool                          `yaml:"-"`
+	RefreshDependencies    []string                      `yaml:"-"`
+	Offline                bool                          `yaml:"-"`
+	GoModCache             string                        `yaml:"-"`
+	GoRoot                 string                        `yaml:"-"`
+	ReportFile             string                        `yaml:"-"`
+	Report                 *Report                       `yaml:"-"`
+	Lock                   *Lock                         `yaml:"-"`
+	GoFiles                []string                      `yaml:"-"`
+	JSFIles                []string                      `yaml:"-"`
+	DockerFiles            []string                      `yaml:"-"`
+}
+
+// DependencyOverride replaces the metadata of a dependency. Empty fields keep
+// the value fetched from the registry or the code host.
+type DependencyOverride struct {
+	Author      string `yaml:"author"`
+	Description string `yaml:"description"`
+	HomePage    string `yaml:"homepage"`
+	License     string `yaml:"license"`
+	LicenseFile string `yaml:"licenseFile"`
+	LicenseURL  string `yaml:"licenseURL"`
+}
+
+func (o DependencyOverride) Apply(d *Dependency) {
+	if o.Author != "" {
+		d.Author = DependencyAuthor{Name: o.Author}
+	}
+	if o.Description != "" {
+		d.Description = o.Description
+	}
+	if o.HomePage != "" {
+		d.HomePage = o.HomePage
+	}
+	if o.License != "" {
+		d.License = o.License
+	}
+	if o.LicenseFile != "" || o.LicenseURL != "" {
+		d.LicenseFile = o.LicenseFile
+		d.LicenseURL = o.LicenseURL
+		d.LicenseText = ""
+	}
+}
+
+// Hash identifies the corrections of the override, so the stanzas generated
+// before they changed are regenerated.
+func (o DependencyOverride) Hash() string {
+	data, _ := yaml.Marshal(o)
+	return textHash(string(data))
+}
+
+// AdditionalDependency is a dependency that is not declared in any manifest.
+// A plain name requires its stanza to be added manually, while an entry with
+// metadata is rendered by the tool.
+type AdditionalDependency struct {
+	Name               string `yaml:"name"`
+	DependencyOverride `yaml:",inline"`
+	Repository         string `yaml:"repository"`
+	Version            string `yaml:"version"`
+}
+
+func (a *AdditionalDependency) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		a.Name = value.Value
+		return nil
+	}
+	type plain AdditionalDependency
+	return value.Decode((*plain)(a))
+}
+
+// HasMetadata reports whether the entry carries enough information for its
+// stanza to be generated.
+func (a AdditionalDependency) HasMetadata() bool {
+	return a != AdditionalDependency{Name: a.Name, Version: a.Version}
+}
+
+// Dependency converts the entry to a dependency, typed as manual when the
+// stanza can be rendered from the configured metadata.
+func (a AdditionalDependency) Dependency() Dependency {
+	if !a.HasMetadata() {
+		return Dependency{Name: a.Name, Version: a.Version}
+	}
+	d := Dependency{
+		Name:           a.Name,
+		Version:        a.Version,
+		Repository:     DependencyRepository{URL: a.Repository},
+		DependencyType: ManualDep,
+		MetadataHash:   a.Hash(),
+	}
+	a.DependencyOverride.Apply(&d)
+	return d
+}
+
+// Hash identifies the configured metadata of the entry, like the Hash of an
+// override.
+func (a AdditionalDependency) Hash() string {
+	data, _ := yaml.Marshal(a)
+	return textHash(string(data))
+}
+
+// OverrideFor returns the override configured for a dependency, looked up by
+// name first, then by full module path and identity.
+func (c *Config) OverrideFor(d *Dependency) (DependencyOverride, bool) {
+	if o, ok := c.Overrides[d.Name]; ok {
+		return o, true
+	}
+	if d.FullName != "" {
+		if o, ok := c.Overrides[d.FullName]; ok {
+			return o, true
+		}
+	}
+	o, ok := c.Overrides[d.Identity()]
+	return o, ok
 }
 
 type Argument struct {
@@ -58,6 +194,11 @@ func (c *Config) determineRepoFiles() {
 			c.GoFiles = append(c.GoFiles, goFile)
 		}
 
+		if strings.Contains(search, "Dockerfile") {
+			dockerFile, _ := filepath.Abs(filepath.Join(c.Path, search))
+			c.DockerFiles = append(c.DockerFiles, dockerFile)
+		}
+
 	}
 }
 
@@ -67,6 +208,21 @@ func getArgs() map[string]string {
 		{"p", ".", "Repository Path"},
 		{"t", "", "Github Authentication Token"},
 		{"c", "", "Configuration File Path"},
+		{"github-url", "", "GitHub Enterprise API URL"},
+		{"github-upload-url", "", "GitHub Enterprise upload URL"},
+		{"github-token", "", "GitHub Enterprise Authentication Token"},
+		{"concurrency", strconv.Itoa(defaultConcurrency), "Number of dependencies processed in parallel"},
+		{"timeout", "30m", "Overall deadline of the run"},
+		{"request-timeout", "30s", "Deadline of every single HTTP request"},
+		{"report", "", "Path of a JSON report of failed and ignored dependencies"},
+		{"cache-dir", DefaultCacheDir(), "Metadata cache directory"},
+		{"cache-ttl", defaultCacheTTL.String(), "Age after which cached metadata is revalidated"},
+		{"refresh", "", "Regenerate the stanzas of a comma separated list of dependencies"},
+	}
+	// Switches are boolean flags, which do not need a value
+	supportedSwitches := []Argument{
+		{"refresh-cache", "false", "Revalidate all cached metadata"},
+		{"offline", "false", "Forbid network access and only use local sources"},
 	}
 	flagsDefined := (flag.Lookup(supportedArguments[0].Name) != nil)
 
@@ -75,10 +231,13 @@ func getArgs() map[string]string {
 		for _, arg := range supportedArguments {
 			_ = flag.String(arg.Name, arg.DefaultValue, arg.Description)
 		}
+		for _, arg := range supportedSwitches {
+			_ = flag.Bool(arg.Name, arg.DefaultValue == "true", arg.Description)
+		}
 	}
 	// The flags are now defined: parse their value, then retrieve it
 	flag.Parse()
-	for _, arg := range supportedArguments {
+	for _, arg := range append(supportedArguments, supportedSwitches...) {
 		m[arg.Name] = (flag.Lookup(arg.Name).Value.String())
 	}
 	return m
@@ -103,8 +262,44 @@ func newConfig() *Config {
 	// Path always exist, no need to check error
 	repoFullPath, _ := filepath.Abs(repositoryPath)
 	config := &Config{
-		Path:    repoFullPath,
-		GHToken: githubToken,
+		Path:                  repoFullPath,
+		GHEnterpriseURL:       args["github-url"],
+		GHEnterpriseUploadURL: args["github-upload-url"],
+		ReportFile:            args["report"],
+		Report:                &Report{},
+		CacheDir:              args["cache-dir"],
+		Refresh:               args["refresh-cache"] == "true",
+		RefreshDependencies:   parseRefreshList(args["refresh"]),
+		Offline:               args["offline"] == "true",
+		GoModCache:            GoModCacheDir(),
+		GoRoot:                GoRootDir(),
+	}
+
+	var source string
+	if config.GHToken, source = ResolveGitHubToken(defaultGitHubHost, githubToken); source != "" {
+		log.Printf("Using the GitHub token from %s", source)
+	}
+	if config.GHEnterpriseURL != "" {
+		host, _ := url.Parse(config.GHEnterpriseURL)
+		if host == nil || host.Host == "" {
+			log.Fatalf("Invalid -github-url %q, an absolute URL is expected", config.GHEnterpriseURL)
+		}
+		if config.GHEnterpriseToken, source = ResolveGitHubToken(strings.ToLower(host.Host), args["github-token"]); source != "" {
+			log.Printf("Using the GitHub Enterprise token from %s", source)
+		}
+	}
+
+	if config.Concurrency, err = strconv.Atoi(args["concurrency"]); err != nil || config.Concurrency < 1 {
+		log.Fatalf("Invalid -concurrency %q, a positive number is expected", args["concurrency"])
+	}
+	if config.Timeout, err = time.ParseDuration(args["timeout"]); err != nil {
+		log.Fatalf("Invalid -timeout %q: %v", args["timeout"], err)
+	}
+	if config.RequestTimeout, err = time.ParseDuration(args["request-timeout"]); err != nil {
+		log.Fatalf("Invalid -request-timeout %q: %v", args["request-timeout"], err)
+	}
+	if config.CacheTTL, err = time.ParseDuration(args["cache-ttl"]); err != nil {
+		log.Fatalf("Invalid -cache-ttl %q: %v", args["cache-ttl"], err)
 	}
 
 	if err = yaml.Unmarshal(content, config); err != nil {
@@ -4,8 +4,10 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
 )
 
 func TestNoticeDirPath(t *testing.T) {
@@ -40,6 +42,12 @@ func TestNewConfig(t *testing.T) {
 	config := newConfig()
 	assert.Equal(t, "/tmp/test", config.Path)
 	assert.Equal(t, "token", config.GHToken)
+	assert.Equal(t, defaultConcurrency, config.Concurrency)
+	assert.Equal(t, 30*time.Minute, config.Timeout)
+	assert.Equal(t, 30*time.Second, config.RequestTimeout)
+	assert.Equal(t, DefaultCacheDir(), config.CacheDir)
+	assert.Equal(t, defaultCacheTTL, config.CacheTTL)
+	assert.False(t, config.Refresh)
 	assert.Equal(t, "Notice Title", config.Title)
 	assert.Equal(t, "Notice Copyright", config.Copyright)
 	assert.Equal(t, "Notice Description", config.Description)
@@ -48,10 +56,68 @@ func TestNewConfig(t *testing.T) {
 	assert.Equal(t, 1, len(config.Search))
 	assert.Equal(t, "package.json", config.Search[0])
 	assert.Equal(t, 2, len(config.AdditionalDependencies))
-	assert.Equal(t, "wix", config.AdditionalDependencies[0])
-	assert.Equal(t, "ignored", config.AdditionalDependencies[1])
+	assert.Equal(t, "wix", config.AdditionalDependencies[0].Name)
+	assert.Equal(t, "ignored", config.AdditionalDependencies[1].Name)
 	assert.Equal(t, 1, len(config.IgnoreDependencies))
-	assert.Equal(t, "ignored", config.IgnoreDependencies[0])
+	assert.Equal(t, "ignored", config.IgnoreDependencies[0].Pattern)
 
 	os.Args = os.Args[:len(os.Args)-3]
 }
+
+func TestAdditionalDependencies(t *testing.T) {
+	var config Config
+	err := yaml.Unmarshal([]byte(`
+additionalDependencies:
+  - wix
+  - name: "Inter font"
+    author: "The Inter Project Authors"
+    homepage: "https://rsms.me/inter/"
+    license: "OFL-1.1"
+    licenseFile: "assets/fonts/LICENSE.txt"
+`), &config)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(config.AdditionalDependencies))
+
+	wix := config.AdditionalDependencies[0]
+	assert.False(t, wix.HasMetadata())
+	assert.Equal(t, Dependency{Name: "wix"}, wix.Dependency())
+
+	inter := config.AdditionalDependencies[1].Dependency()
+	assert.Equal(t, ManualDep, inter.DependencyType)
+	assert.Equal(t, "Inter font", inter.Name)
+	assert.Equal(t, "The Inter Project Authors", inter.Author.Name)
+	assert.Equal(t, "https://rsms.me/inter/", inter.HomePage)
+	assert.Equal(t, "OFL-1.1", inter.License)
+	assert.Equal(t, "assets/fonts/LICENSE.txt", inter.LicenseFile)
+}
+
+func TestOverrides(t *testing.T) {
+	var config Config
+	err := yaml.Unmarshal([]byte(`
+overrides:
+  "github.com/golang/protobuf":
+    author: "The Go Authors"
+    license: "BSD-3-Clause"
+  react:
+    licenseURL: "https://example.com/LICENSE"
+`), &config)
+	assert.NoError(t, err)
+
+	dep := Dependency{Name: "golang/protobuf", FullName: "github.com/golang/protobuf", Author: DependencyAuthor{Name: "golang"}, License: "NOASSERTION", Description: "Go support for Protocol Buffers"}
+	override, ok := config.OverrideFor(&dep)
+	assert.True(t, ok)
+	override.Apply(&dep)
+	assert.Equal(t, "The Go Authors", dep.Author.Name)
+	assert.Equal(t, "BSD-3-Clause", dep.License)
+	assert.Equal(t, "Go support for Protocol Buffers", dep.Description)
+
+	dep = Dependency{Name: "react", LicenseText: "fetched"}
+	override, ok = config.OverrideFor(&dep)
+	assert.True(t, ok)
+	override.Apply(&dep)
+	assert.Equal(t, "https://example.com/LICENSE", dep.LicenseURL)
+	assert.Equal(t, "", dep.LicenseText)
+
+	_, ok = config.OverrideFor(&Dependency{Name: "other"})
+	assert.False(t, ok)
+}
@@ -0,0 +1,585 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var regexpDockerVariable = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::([-+])([^}]*))?\}|\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+// ContainerImage is a base image referenced by a FROM instruction.
+type ContainerImage struct {
+	Reference string
+	Stage     string
+}
+
+// Repository returns the image reference without its tag or digest.
+func (i ContainerImage) Repository() string {
+	ref := i.Reference
+	if idx := strings.Index(ref, "@"); idx >= 0 {
+		ref = ref[:idx]
+	}
+	if idx := strings.LastIndex(ref, ":"); idx > strings.LastIndex(ref, "/") {
+		ref = ref[:idx]
+	}
+	return ref
+}
+
+// Tag returns the tag or digest of the image reference, the digest being
+// preferred as it pins the image.
+func (i ContainerImage) Tag() string {
+	ref := i.Reference
+	if idx := strings.Index(ref, "@"); idx >= 0 {
+		return ref[idx+1:]
+	}
+	if idx := strings.LastIndex(ref, ":"); idx > strings.LastIndex(ref, "/") {
+		return ref[idx+1:]
+	}
+	return ""
+}
+
+// HomePage returns the Docker Hub page for images hosted there.
+func (i ContainerImage) HomePage() string {
+	repo := i.Repository()
+	parts := strings.Split(repo, "/")
+	if len(parts) > 1 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		if parts[0] != "docker.io" {
+			return ""
+		}
+		parts = parts[1:]
+	}
+	if len(parts) == 1 || parts[0] == "library" {
+		return "https://hub.docker.com/_/" + parts[len(parts)-1]
+	}
+	return "https://hub.docker.com/r/" + strings.Join(parts, "/")
+}
+
+func expandDockerVariables(s string, vars map[string]string) string {
+	return regexpDockerVariable.ReplaceAllStringFunc(s, func(m string) string {
+		sub := regexpDockerVariable.FindStringSubmatch(m)
+		name := sub[1]
+		if name == "" {
+			return vars[sub[4]]
+		}
+		value, ok := vars[name]
+		switch sub[2] {
+		case "-":
+			if !ok || value == "" {
+				return sub[3]
+			}
+		case "+":
+			if ok && value != "" {
+				return sub[3]
+			}
+			return ""
+		}
+		return value
+	})
+}
+
+// dockerInstructions returns the instructions of a Dockerfile with comments
+// removed and line continuations joined.
+func dockerInstructions(r io.Reader) ([]string, error) {
+	var instructions []string
+	var current strings.Builder
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasSuffix(line, "\\") {
+			current.WriteString(strings.TrimSuffix(line, "\\"))
+			current.WriteString(" ")
+			continue
+		}
+		current.WriteString(line)
+		if instruction := strings.TrimSpace(current.String()); instruction != "" {
+			instructions = append(instructions, instruction)
+		}
+		current.Reset()
+	}
+	if instruction := strings.TrimSpace(current.String()); instruction != "" {
+		instructions = append(instructions, instruction)
+	}
+	return instructions, scanner.Err()
+}
+
+// ParseDockerfile returns the external base images of a Dockerfile. Global
+// ARG defaults and the given build arguments are substituted, while FROM
+// instructions referring to an earlier build stage or to scratch are skipped.
+func ParseDockerfile(r io.Reader, buildArgs map[string]string) ([]ContainerImage, error) {
+	instructions, err := dockerInstructions(r)
+	if err != nil {
+		return nil, err
+	}
+
+	args := make(map[string]string)
+	stages := make(map[string]bool)
+	var images []ContainerImage
+	seenFrom := false
+
+	for _, instruction := range instructions {
+		fields := strings.Fields(instruction)
+		switch strings.ToUpper(fields[0]) {
+		case "ARG":
+			// Only ARGs declared before the first FROM are in scope for FROM lines
+			if seenFrom {
+				continue
+			}
+			for _, arg := range fields[1:] {
+				name, value, _ := strings.Cut(arg, "=")
+				value = strings.Trim(value, `"'`)
+				if override, ok := buildArgs[name]; ok {
+					value = override
+				}
+				args[name] = value
+			}
+		case "FROM":
+			seenFrom = true
+			var params []string
+			for _, f := range fields[1:] {
+				if !strings.HasPrefix(f, "--") {
+					params = append(params, f)
+				}
+			}
+			if len(params) == 0 {
+				return nil, fmt.Errorf("invalid FROM instruction %q", instruction)
+			}
+			image := ContainerImage{Reference: expandDockerVariables(params[0], args)}
+			if len(params) >= 3 && strings.EqualFold(params[1], "as") {
+				image.Stage = params[2]
+			}
+			external := image.Reference != "" && image.Reference != "scratch" && !stages[strings.ToLower(image.Reference)]
+			if image.Stage != "" {
+				stages[strings.ToLower(image.Stage)] = true
+			}
+			if external {
+				images = append(images, image)
+			}
+		}
+	}
+	return images, nil
+}
+
+// imageRoot gives read access to the files of an exported container image
+// filesystem, either unpacked in a directory or as a tarball.
+type imageRoot interface {
+	ReadFile(name string) ([]byte, error)
+	ReadDir(dir string) ([]string, error)
+}
+
+type dirImageRoot string
+
+func (r dirImageRoot) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(string(r), filepath.FromSlash(name)))
+}
+
+func (r dirImageRoot) ReadDir(dir string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(string(r), filepath.FromSlash(dir)))
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
+
+// tarImageRoot holds the package database files of an exported image tarball.
+type tarImageRoot map[string][]byte
+
+func (r tarImageRoot) ReadFile(name string) ([]byte, error) {
+	if data, ok := r[name]; ok {
+		return data, nil
+	}
+	return nil, os.ErrNotExist
+}
+
+func (r tarImageRoot) ReadDir(dir string) ([]string, error) {
+	var names []string
+	for name := range r {
+		if path.Dir(name) == dir {
+			names = append(names, path.Base(name))
+		}
+	}
+	if len(names) == 0 {
+		return nil, os.ErrNotExist
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// isImagePackageFile reports whether a file of an image filesystem is needed
+// to enumerate its packages, so that only those are kept in memory.
+func isImagePackageFile(name string) bool {
+	switch {
+	case name == "var/lib/dpkg/status", name == "lib/apk/db/installed":
+		return true
+	case name == "etc/os-release", name == "usr/lib/os-release":
+		return true
+	case strings.HasPrefix(name, "var/lib/dpkg/status.d/"):
+		return true
+	case strings.HasPrefix(name, "usr/share/doc/") && path.Base(name) == "copyright":
+		return true
+	case strings.HasPrefix(name, "var/lib/rpm/"), strings.HasPrefix(name, "usr/lib/sysimage/rpm/"):
+		return true
+	}
+	return false
+}
+
+func openImageRoot(location string) (imageRoot, error) {
+	info, err := os.Stat(location)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return dirImageRoot(location), nil
+	}
+
+	f, err := os.Open(location)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(location, ".gz") || strings.HasSuffix(location, ".tgz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	root := tarImageRoot{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading image tarball %s: %w", location, err)
+		}
+		name := strings.TrimPrefix(path.Clean("/"+hdr.Name), "/")
+		if hdr.Typeflag != tar.TypeReg || !isImagePackageFile(name) {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		root[name] = data
+	}
+	return root, nil
+}
+
+// parseControlStanzas parses RFC 822 style paragraphs as used by the dpkg
+// status database. Continuation lines are appended to the previous field.
+func parseControlStanzas(data []byte) []map[string]string {
+	var stanzas []map[string]string
+	current := map[string]string{}
+	last := ""
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.TrimSpace(line) == "":
+			if len(current) > 0 {
+				stanzas = append(stanzas, current)
+			}
+			current = map[string]string{}
+			last = ""
+		case line[0] == ' ' || line[0] == '\t':
+			if last != "" {
+				current[last] += "\n" + strings.TrimSpace(line)
+			}
+		default:
+			key, value, _ := strings.Cut(line, ":")
+			last = key
+			current[key] = strings.TrimSpace(value)
+		}
+	}
+	if len(current) > 0 {
+		stanzas = append(stanzas, current)
+	}
+	return stanzas
+}
+
+// dep5License returns the license of the Files: * paragraph of a machine
+// readable debian/copyright file, if it is in that format.
+func dep5License(copyright []byte) string {
+	for _, stanza := range parseControlStanzas(copyright) {
+		if stanza["Files"] == "*" && stanza["License"] != "" {
+			license, _, _ := strings.Cut(stanza["License"], "\n")
+			return license
+		}
+	}
+	return ""
+}
+
+// osReleaseID returns the ID of the distribution of an image, as named in
+// os-release, which is the namespace of the package URLs of its packages.
+func osReleaseID(root imageRoot) string {
+	for _, name := range []string{"etc/os-release", "usr/lib/os-release"} {
+		data, err := root.ReadFile(name)
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if value, ok := strings.CutPrefix(strings.TrimSpace(line), "ID="); ok {
+				return strings.ToLower(strings.Trim(value, `"'`))
+			}
+		}
+	}
+	return ""
+}
+
+func debianPackages(root imageRoot) ([]Dependency, error) {
+	var databases [][]byte
+	data, err := root.ReadFile("var/lib/dpkg/status")
+	if err == nil {
+		databases = append(databases, data)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	// Distroless images keep one status file per package
+	if names, err := root.ReadDir("var/lib/dpkg/status.d"); err == nil {
+		for _, name := range names {
+			if data, err := root.ReadFile("var/lib/dpkg/status.d/" + name); err == nil {
+				databases = append(databases, data)
+			}
+		}
+	}
+
+	distribution := osReleaseID(root)
+	if distribution == "" {
+		distribution = "debian"
+	}
+	var deps []Dependency
+	for _, data := range databases {
+		for _, pkg := range parseControlStanzas(data) {
+			name := pkg["Package"]
+			if name == "" {
+				continue
+			}
+			if status, ok := pkg["Status"]; ok && !strings.HasSuffix(status, " installed") {
+				continue
+			}
+			// The first line of the description is the synopsis of the package
+			description, _, _ := strings.Cut(pkg["Description"], "\n")
+			if description == "" {
+				description = fmt.Sprintf("Debian package %s, version %s", name, pkg["Version"])
+			}
+			dep := Dependency{
+				Name:           name,
+				Description:    description,
+				Purl:           Purl("deb", distribution+"/"+name),
+				Version:        pkg["Version"],
+				HomePage:       pkg["Homepage"],
+				DependencyType: ContainerDep,
+			}
+			if maintainer := pkg["Maintainer"]; maintainer != "" {
+				dep.Author = DependencyAuthor{Name: maintainer}
+			}
+			if copyright, err := root.ReadFile("usr/share/doc/" + name + "/copyright"); err == nil {
+				dep.License = dep5License(copyright)
+				dep.LicenseText = string(copyright)
+			}
+			deps = append(deps, dep)
+		}
+	}
+	return deps, nil
+}
+
+func alpinePackages(root imageRoot) ([]Dependency, error) {
+	data, err := root.ReadFile("lib/apk/db/installed")
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	distribution := osReleaseID(root)
+	if distribution == "" {
+		distribution = "alpine"
+	}
+	var deps []Dependency
+	pkg := map[string]string{}
+	flush := func() {
+		if pkg["P"] != "" {
+			description := pkg["T"]
+			if description == "" {
+				description = fmt.Sprintf("Alpine package %s, version %s", pkg["P"], pkg["V"])
+			}
+			deps = append(deps, Dependency{
+				Name:           pkg["P"],
+				Purl:           Purl("apk", distribution+"/"+pkg["P"]),
+				Description:    description,
+				Version:        pkg["V"],
+				HomePage:       pkg["U"],
+				License:        pkg["L"],
+				Author:         DependencyAuthor{Name: pkg["m"]},
+				DependencyType: ContainerDep,
+			})
+		}
+		pkg = map[string]string{}
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			flush()
+			continue
+		}
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			pkg[key] = value
+		}
+	}
+	flush()
+	return deps, scanner.Err()
+}
+
+func rpmPackages(root imageRoot) ([]Dependency, error) {
+	var dbDir string
+	for _, dir := range []string{"var/lib/rpm", "usr/lib/sysimage/rpm"} {
+		if names, err := root.ReadDir(dir); err == nil && len(names) > 0 {
+			dbDir = dir
+			break
+		}
+	}
+	if dbDir == "" {
+		return nil, nil
+	}
+
+	rpm, err := exec.LookPath("rpm")
+	if err != nil {
+		log.Printf("Skipping RPM packages, the rpm binary is not available")
+		return nil, nil
+	}
+
+	dbPath := ""
+	switch r := root.(type) {
+	case dirImageRoot:
+		dbPath = filepath.Join(string(r), filepath.FromSlash(dbDir))
+	case tarImageRoot:
+		tmp, err := os.MkdirTemp("", "notice-rpmdb")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(tmp)
+		names, _ := r.ReadDir(dbDir)
+		for _, name := range names {
+			if err := os.WriteFile(filepath.Join(tmp, name), r[dbDir+"/"+name], 0644); err != nil {
+				return nil, err
+			}
+		}
+		dbPath = tmp
+	}
+
+	out, err := exec.Command(rpm, "--dbpath", dbPath, "-qa", "--queryformat", "%{NAME}\t%{VERSION}-%{RELEASE}\t%{LICENSE}\t%{URL}\t%{VENDOR}\n").Output()
+	if err != nil {
+		return nil, fmt.Errorf("querying rpm database: %w", err)
+	}
+
+	// The namespace of RPM package URLs is the vendor of the distribution
+	namespace := ""
+	if distribution := osReleaseID(root); distribution != "" {
+		namespace = distribution + "/"
+	}
+	var deps []Dependency
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 5 || fields[0] == "gpg-pubkey" {
+			continue
+		}
+		dep := Dependency{
+			Name:           fields[0],
+			Purl:           Purl("rpm", namespace+fields[0]),
+			Description:    fmt.Sprintf("RPM package %s, version %s", fields[0], fields[1]),
+			Version:        fields[1],
+			License:        fields[2],
+			DependencyType: ContainerDep,
+		}
+		if fields[3] != "(none)" {
+			dep.HomePage = fields[3]
+		}
+		if fields[4] != "(none)" {
+			dep.Author = DependencyAuthor{Name: fields[4]}
+		}
+		deps = append(deps, dep)
+	}
+	return deps, nil
+}
+
+// PopulateDockerfileDependencies lists the base images of a Dockerfile.
+func (c *Config) PopulateDockerfileDependencies(dockerfile string) ([]Dependency, error) {
+	f, err := os.Open(dockerfile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	images, err := ParseDockerfile(f, c.DockerBuildArgs)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", dockerfile, err)
+	}
+
+	var deps []Dependency
+	for _, image := range images {
+		log.Printf("Found %s base image in %s", image.Reference, dockerfile)
+		deps = append(deps, Dependency{
+			Name:           image.Repository(),
+			FullName:       image.Reference,
+			Description:    fmt.Sprintf("Container base image %s", image.Reference),
+			HomePage:       image.HomePage(),
+			Version:        image.Tag(),
+			DependencyType: ContainerDep,
+		})
+	}
+	return deps, nil
+}
+
+// PopulateImageDependencies lists the OS packages installed in an exported
+// container image filesystem.
+func (c *Config) PopulateImageDependencies(image string) ([]Dependency, error) {
+	root, err := openImageRoot(image)
+	if err != nil {
+		return nil, err
+	}
+
+	var deps []Dependency
+	for _, lister := range []func(imageRoot) ([]Dependency, error){debianPackages, alpinePackages, rpmPackages} {
+		d, err := lister(root)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", image, err)
+		}
+		deps = append(deps, d...)
+	}
+	log.Printf("Found %d packages in container image %s", len(deps), image)
+	return deps, nil
+}
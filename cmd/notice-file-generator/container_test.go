@@ -0,0 +1,145 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDockerfile(t *testing.T) {
+	f, err := os.Open("testdata/Dockerfile")
+	require.NoError(t, err)
+	defer f.Close()
+
+	images, err := ParseDockerfile(f, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []ContainerImage{
+		{Reference: "golang:1.21.8", Stage: "builder"},
+		{Reference: "gcr.io/distroless/static@sha256:d6fa9db9548b5772860fecddb11d84f9ebd7e0321c0cb3c02870402680cc315f", Stage: "runner"},
+	}, images)
+}
+
+func TestParseDockerfileBuildArgs(t *testing.T) {
+	f, err := os.Open("testdata/Dockerfile")
+	require.NoError(t, err)
+	defer f.Close()
+
+	images, err := ParseDockerfile(f, map[string]string{"GO_IMAGE": "golang:1.22", "RUNNER_REGISTRY": "docker.io"})
+	assert.NoError(t, err)
+	assert.Len(t, images, 2)
+	assert.Equal(t, "golang:1.22", images[0].Reference)
+	assert.Equal(t, "docker.io/distroless/static", images[1].Repository())
+}
+
+func TestContainerImageHomePage(t *testing.T) {
+	assert.Equal(t, "https://hub.docker.com/_/golang", ContainerImage{Reference: "golang:1.21.8"}.HomePage())
+	assert.Equal(t, "https://hub.docker.com/_/alpine", ContainerImage{Reference: "docker.io/library/alpine:3.19"}.HomePage())
+	assert.Equal(t, "https://hub.docker.com/r/mattermost/mattermost-enterprise-edition", ContainerImage{Reference: "mattermost/mattermost-enterprise-edition"}.HomePage())
+	assert.Equal(t, "", ContainerImage{Reference: "gcr.io/distroless/static"}.HomePage())
+	assert.Equal(t, "localhost:5000/app", ContainerImage{Reference: "localhost:5000/app:1.0"}.Repository())
+}
+
+func assertImagePackages(t *testing.T, deps []Dependency) {
+	names := make(map[string]Dependency)
+	for _, d := range deps {
+		assert.Equal(t, ContainerDep, d.DependencyType)
+		names[d.Name] = d
+	}
+	assert.Len(t, names, 4)
+
+	libc := names["libc6"]
+	assert.Equal(t, "LGPL-2.1+", libc.License)
+	assert.Equal(t, "https://www.gnu.org/software/libc/libc.html", libc.HomePage)
+	assert.Equal(t, "GNU C Library: Shared libraries", libc.Description)
+	assert.Contains(t, libc.LicenseText, "Free Software Foundation")
+
+	assert.Contains(t, names["tzdata"].LicenseText, "public domain")
+	assert.Equal(t, "MIT", names["musl"].License)
+	assert.Equal(t, "the musl c library (libc) implementation", names["musl"].Description)
+	assert.Equal(t, "Natanael Copa <ncopa@alpinelinux.org>", names["musl"].Author.Name)
+	assert.Equal(t, "GPL-2.0-only", names["busybox"].License)
+}
+
+func TestPopulateImageDependencies(t *testing.T) {
+	config := Config{}
+	deps, err := config.PopulateImageDependencies("testdata/image")
+	assert.NoError(t, err)
+	assertImagePackages(t, deps)
+}
+
+func TestOSReleaseID(t *testing.T) {
+	assert.Equal(t, "fedora", osReleaseID(tarImageRoot{"etc/os-release": []byte("NAME=\"Fedora Linux\"\nVERSION_ID=40\nID=fedora\n")}))
+	assert.Equal(t, "rhel", osReleaseID(tarImageRoot{"usr/lib/os-release": []byte("ID=\"rhel\"\nID_LIKE=\"fedora\"\n")}))
+	assert.Equal(t, "", osReleaseID(tarImageRoot{}))
+	assert.True(t, isImagePackageFile("etc/os-release"))
+}
+
+func TestPopulateImageDependenciesUnreadableDatabase(t *testing.T) {
+	root := t.TempDir()
+	// The database is a directory, it cannot be read
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "lib", "apk", "db", "installed"), os.ModePerm))
+
+	config := Config{}
+	_, err := config.PopulateImageDependencies(root)
+	assert.Error(t, err)
+}
+
+func TestPopulateImageDependenciesTarball(t *testing.T) {
+	tarball := filepath.Join(t.TempDir(), "image.tar.gz")
+	out, err := os.Create(tarball)
+	require.NoError(t, err)
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+	err = filepath.Walk("testdata/image", func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel("testdata/image", p)
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if err = tw.WriteHeader(&tar.Header{Name: "./" + filepath.ToSlash(rel), Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			return err
+		}
+		_, err = tw.Write(data)
+		return err
+	})
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	require.NoError(t, out.Close())
+
+	config := Config{}
+	deps, err := config.PopulateImageDependencies(tarball)
+	assert.NoError(t, err)
+	assertImagePackages(t, deps)
+}
+
+func TestContainerSectionRoundTrip(t *testing.T) {
+	config := &Config{Path: t.TempDir(), Title: "Title"}
+	require.NoError(t, CreateNoticeDir(config))
+	deps := []Dependency{
+		{Name: "app-dep", DependencyType: GoDep},
+		{Name: "golang", DependencyType: ContainerDep},
+	}
+	for _, d := range deps {
+		stanza := "## " + d.Name + "\n\nThis product contains '" + d.Name + "'.\n\n"
+		require.NoError(t, os.WriteFile(filepath.Join(config.NoticeWorkPath(), d.FileName()), []byte(stanza), 0644))
+	}
+	require.NoError(t, UpdateNotice(config, deps))
+
+	notice, err := os.ReadFile(config.NoticeFilePath())
+	require.NoError(t, err)
+	assert.Contains(t, string(notice), "--------\n\n"+containerSectionHeading+"\n--------\n\n## golang")
+
+	require.NoError(t, SplitExistingNotice(config))
+	stanza, err := os.ReadFile(filepath.Join(config.NoticeDirPath(), "app-dep"))
+	require.NoError(t, err)
+	assert.Equal(t, "## app-dep\n\nThis product contains 'app-dep'.\n\n", string(stanza))
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Copyright is a copyright statement found in a license file or a source
+// header.
+type Copyright struct {
+	Years  string `json:"years,omitempty"`
+	Holder string `json:"holder"`
+}
+
+func (c Copyright) String() string {
+	if c.Years == "" {
+		return "Copyright (c) " + c.Holder
+	}
+	return "Copyright (c) " + c.Years + " " + c.Holder
+}
+
+// maxAttributedHolders is the number of holders named in the stanza, the
+// others being summarised as "and others".
+const maxAttributedHolders = 3
+
+var (
+	// regexpCopyrightStatement matches a line starting with a copyright
+	// statement, after any comment marker. A bare "(c)" or "©" only starts a
+	// statement when a year follows, as "(c)" is also a list marker of
+	// license terms.
+	regexpCopyrightStatement = regexp.MustCompile(`(?i)^[\s/*#;!-]*(?:(copyright)\b\s*:?\s*(\(c\)|©)?\s*(.*)|(?:\(c\)|©)\s*((?:19|20)[0-9]{2}\b.*))$`)
+	regexpLeadingYears       = regexp.MustCompile(`^((?:19|20)[0-9]{2}(?:\s*(?:-|–|,)\s*(?:(?:19|20)[0-9]{2}|present))*)[,.]?\s*`)
+	regexpTrailingYears      = regexp.MustCompile(`,?\s+((?:19|20)[0-9]{2}(?:\s*(?:-|–|,)\s*(?:(?:19|20)[0-9]{2}|present))*)$`)
+	regexpEmail              = regexp.MustCompile(`\s*<[^>]*@[^>]*>`)
+	regexpRightsReserved     = regexp.MustCompile(`(?i)[,.]?\s*all rights reserved\.?`)
+	// regexpLicenseTerms matches the words and phrases of license terms and
+	// placeholders, ie. "<year> <copyright holders>" or "notice and this
+	// permission notice", as whole words so "Goodyear" or "Notice Corp" are
+	// still holders.
+	regexpLicenseTerms = regexp.MustCompile(`(?i)\b(?:(?:years?|yyyy|holders?|owners?|licen[cs]e[ds]?|statements?|the above|name of)\b|notices?(?:\s*[,;.]|\s+(?:and|or|of|in|this|that|shall|from)\b|$))`)
+)
+
+// sourceExtensions are the files whose header is searched for copyright
+// statements when the license has none.
+var sourceExtensions = map[string]bool{
+	".go": true, ".js": true, ".mjs": true, ".ts": true, ".c": true, ".h": true, ".cc": true, ".cpp": true,
+	".py": true, ".java": true, ".rs": true, ".rb": true, ".php": true, ".swift": true, ".kt": true,
+}
+
+const (
+	// maxSourceFiles bounds the number of source headers read in a directory.
+	maxSourceFiles = 200
+	// sourceHeaderLines is the number of lines of a source file considered as
+	// its header.
+	sourceHeaderLines = 30
+)
+
+// ExtractCopyrights returns the distinct copyright statements of a text, in
+// order of appearance. Placeholders of license templates and the mentions of
+// "copyright holders" in license terms are skipped.
+func ExtractCopyrights(text string) []Copyright {
+	var copyrights []Copyright
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(text, "\n") {
+		c, ok := parseCopyright(line)
+		if !ok || seen[strings.ToLower(c.Holder)] {
+			continue
+		}
+		seen[strings.ToLower(c.Holder)] = true
+		copyrights = append(copyrights, c)
+	}
+	return copyrights
+}
+
+func parseCopyright(line string) (Copyright, bool) {
+	m := regexpCopyrightStatement.FindStringSubmatch(strings.TrimSpace(line))
+	if m == nil {
+		return Copyright{}, false
+	}
+	rest := regexpRightsReserved.ReplaceAllString(m[3]+m[4], "")
+	rest = regexpEmail.ReplaceAllString(rest, "")
+	rest = strings.TrimSpace(rest)
+
+	var c Copyright
+	if y := regexpLeadingYears.FindStringSubmatch(rest); y != nil {
+		c.Years = y[1]
+		rest = rest[len(y[0]):]
+	} else if y := regexpTrailingYears.FindStringSubmatch(rest); y != nil {
+		c.Years = y[1]
+		rest = rest[:len(rest)-len(y[0])]
+	}
+	// A lowercase "copyright" with neither mark nor year is a line of license
+	// terms wrapped before the word, ie. "copyright law: that is to say".
+	if m[1] == "copyright" && m[2] == "" && c.Years == "" {
+		return Copyright{}, false
+	}
+	rest = strings.TrimPrefix(strings.TrimSpace(rest), "by ")
+	c.Holder = strings.TrimSpace(strings.TrimRight(rest, " ,;.*/"))
+
+	if !isCopyrightHolder(c.Holder) {
+		return Copyright{}, false
+	}
+	return c, true
+}
+
+// isCopyrightHolder rules out the placeholders and license terms matched as
+// statements, ie. "<year> <copyright holders>" or "notice and this permission".
+func isCopyrightHolder(holder string) bool {
+	if holder == "" || len(holder) > 120 || strings.ContainsAny(holder[:1], "<[{(") {
+		return false
+	}
+	if !strings.ContainsFunc(holder, func(r rune) bool { return r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' }) {
+		return false
+	}
+	return !regexpLicenseTerms.MatchString(holder)
+}
+
+// FormatHolders names the copyright holders for the stanza, ie. "A", "A and
+// B" or "A, B, C and others".
+func FormatHolders(copyrights []Copyright) string {
+	var holders []string
+	for _, c := range copyrights {
+		holders = append(holders, c.Holder)
+	}
+	switch {
+	case len(holders) == 0:
+		return ""
+	case len(holders) == 1:
+		return holders[0]
+	case len(holders) > maxAttributedHolders:
+		return strings.Join(holders[:maxAttributedHolders], ", ") + " and others"
+	}
+	return strings.Join(holders[:len(holders)-1], ", ") + " and " + holders[len(holders)-1]
+}
+
+// SourceCopyrights returns the copyright statements of the headers of the
+// source files of a directory, for licenses which do not name the holder,
+// ie. the Apache License.
+func SourceCopyrights(dir string) []Copyright {
+	var copyrights []Copyright
+	seen := make(map[string]bool)
+	files := 0
+	_ = filepath.WalkDir(dir, func(p string, e fs.DirEntry, err error) error {
+		if err != nil || files >= maxSourceFiles {
+			return filepath.SkipDir
+		}
+		if e.IsDir() {
+			if p != dir && (strings.HasPrefix(e.Name(), ".") || e.Name() == "node_modules" || e.Name() == "vendor" || e.Name() == "testdata") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !sourceExtensions[strings.ToLower(filepath.Ext(p))] {
+			return nil
+		}
+		files++
+		for _, c := range ExtractCopyrights(readHeader(p)) {
+			if !seen[strings.ToLower(c.Holder)] {
+				seen[strings.ToLower(c.Holder)] = true
+				copyrights = append(copyrights, c)
+			}
+		}
+		return nil
+	})
+	return copyrights
+}
+
+func readHeader(name string) string {
+	f, err := os.Open(name)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(io.LimitReader(f, 8192))
+	for len(lines) < sourceHeaderLines && scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// loadCopyrights sets the copyright statements of the dependency from its
+// license text, or from the headers of its sources in dir when available.
+func (d *Dependency) loadCopyrights(dir string) {
+	d.Copyrights = ExtractCopyrights(d.LicenseText)
+	if len(d.Copyrights) == 0 && dir != "" {
+		d.Copyrights = SourceCopyrights(dir)
+	}
+}
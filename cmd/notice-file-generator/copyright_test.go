@@ -0,0 +1,85 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractCopyrights(t *testing.T) {
+	copyrights := ExtractCopyrights(`The MIT License (MIT)
+
+Copyright (c) 2015-2020 Jane Doe and contributors
+Copyright © 2021, 2022 Acme Corp. All rights reserved.
+(c) 2014 John Smith <john@example.com>
+Copyright Jane Doe and contributors 2023
+Copyright: The Widget Authors, 2019
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"`)
+	assert.Equal(t, []Copyright{
+		{Years: "2015-2020", Holder: "Jane Doe and contributors"},
+		{Years: "2021, 2022", Holder: "Acme Corp"},
+		{Years: "2014", Holder: "John Smith"},
+		{Years: "2019", Holder: "The Widget Authors"},
+	}, copyrights)
+
+	// License templates only hold placeholders
+	assert.Empty(t, ExtractCopyrights("Copyright (c) <year> <copyright holders>"))
+	assert.Empty(t, ExtractCopyrights("   Copyright [yyyy] [name of copyright owner]"))
+	assert.Empty(t, ExtractCopyrights("Copyright 2019"))
+
+	// List items and wrapped lines of license terms are not statements
+	apache, ok := StandardLicenseText("Apache-2.0")
+	assert.True(t, ok)
+	assert.Empty(t, ExtractCopyrights(apache))
+	assert.Empty(t, ExtractCopyrights("(c) You must retain, in the Source form of any Derivative Works"))
+	assert.Empty(t, ExtractCopyrights("copyright law: that is to say, a work containing the Library"))
+	assert.Equal(t, []Copyright{{Years: "2014", Holder: "John Smith"}}, ExtractCopyrights("© 2014 John Smith"))
+
+	// Terms only rule out holders as whole words
+	assert.Equal(t, []Copyright{
+		{Years: "2020", Holder: "Goodyear"},
+		{Years: "2021", Holder: "Ownership Labs"},
+		{Years: "2022", Holder: "Notice Corp"},
+	}, ExtractCopyrights("Copyright 2020 Goodyear\nCopyright 2021 Ownership Labs\nCopyright (c) 2022 Notice Corp"))
+
+	// Source headers
+	assert.Equal(t, []Copyright{{Years: "2009", Holder: "The Go Authors"}}, ExtractCopyrights("// Copyright 2009 The Go Authors. All rights reserved.\n// Use of this source code is governed by a BSD-style"))
+	assert.Equal(t, []Copyright{{Holder: "Example, Inc"}}, ExtractCopyrights("/*\n * Copyright (C) Example, Inc.\n */"))
+	assert.Equal(t, "Copyright (c) 2009 The Go Authors", Copyright{Years: "2009", Holder: "The Go Authors"}.String())
+}
+
+func TestFormatHolders(t *testing.T) {
+	assert.Equal(t, "", FormatHolders(nil))
+	assert.Equal(t, "A", FormatHolders([]Copyright{{Holder: "A"}}))
+	assert.Equal(t, "A and B", FormatHolders([]Copyright{{Holder: "A"}, {Holder: "B"}}))
+	assert.Equal(t, "A, B and C", FormatHolders([]Copyright{{Holder: "A"}, {Holder: "B"}, {Holder: "C"}}))
+	assert.Equal(t, "A, B, C and others", FormatHolders([]Copyright{{Holder: "A"}, {Holder: "B"}, {Holder: "C"}, {Holder: "D"}}))
+}
+
+func TestSourceCopyrights(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "main.go"), "// Copyright 2021 The Widget Authors\n\npackage main\n")
+	writeTestFile(t, filepath.Join(dir, "lib/util.js"), "/**\n * Copyright (c) 2022 Acme Corp\n */\n")
+	writeTestFile(t, filepath.Join(dir, "tools/other.go"), "// Copyright 2022 The Widget Authors\n")
+	writeTestFile(t, filepath.Join(dir, "node_modules/dep/index.js"), "// Copyright 2020 Someone Else\n")
+	writeTestFile(t, filepath.Join(dir, "README.md"), "Copyright 2020 Someone Else\n")
+
+	assert.ElementsMatch(t, []Copyright{
+		{Years: "2021", Holder: "The Widget Authors"},
+		{Years: "2022", Holder: "Acme Corp"},
+	}, SourceCopyrights(dir))
+
+	// The license text takes precedence over the sources
+	dep := Dependency{LicenseText: mitLicense}
+	dep.loadCopyrights(dir)
+	assert.Equal(t, []Copyright{{Years: "2020", Holder: "Jane Doe"}}, dep.Copyrights)
+
+	dep = Dependency{LicenseText: "Apache License\nVersion 2.0, January 2004"}
+	dep.loadCopyrights(dir)
+	assert.Len(t, dep.Copyrights, 2)
+}
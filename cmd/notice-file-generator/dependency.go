@@ -4,16 +4,17 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"path"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"sync"
 
-	"github.com/google/go-github/github"
 	"golang.org/x/mod/modfile"
-	"golang.org/x/oauth2"
 )
 
 type DependencyType int
@@ -23,8 +24,27 @@ const (
 	_ DependencyType = iota
 	JsDep
 	GoDep
+	ContainerDep
+	VendoredDep
+	ManualDep
 )
 
+func (t DependencyType) String() string {
+	switch t {
+	case JsDep:
+		return "npm"
+	case GoDep:
+		return "go"
+	case ContainerDep:
+		return "container"
+	case VendoredDep:
+		return "vendored"
+	case ManualDep:
+		return "manual"
+	}
+	return "unknown"
+}
+
 type NpmPackage struct {
 	Dependencies    map[string]string `json:"dependencies"`
 	DevDependencies map[string]string `json:"devDependencies"`
@@ -47,14 +67,37 @@ type Dependency struct {
 	Description    string               `json:"description"`
 	Author         DependencyAuthor     `json:"author"`
 	License        string               `json:"license"`
+	LicenseID      string               `json:"-"`
 	Repository     DependencyRepository `json:"repository"`
 	HomePage       string               `json:"homepage"`
 	DependencyType DependencyType       `json:"-"`
+	Purl           string               `json:"-"`
+	Version        string               `json:"-"`
+	Versions       []string             `json:"-"`
+	Manifest       string               `json:"-"`
+	LicenseText    string               `json:"-"`
+	NoticeText     string               `json:"-"`
+	Copyrights     []Copyright          `json:"-"`
+	Location       string               `json:"-"`
+	LicenseFile    string               `json:"-"`
+	LicenseURL     string               `json:"-"`
+
+	// LicenseExpression is the declared license when it is an SPDX
+	// expression, ElectedLicense the one kept after resolving its choices
+	LicenseExpression *LicenseExpression `json:"-"`
+	ElectedLicense    *LicenseExpression `json:"-"`
+	// StandardLicenses are the licenses whose text comes from the embedded
+	// corpus instead of the dependency
+	StandardLicenses []string `json:"-"`
+	// MetadataHash identifies the configured metadata of an additional
+	// dependency, so its stanza is regenerated when the configuration changes
+	MetadataHash string `json:"-"`
 }
 
 type DependencyRepository struct {
-	Type string `json:"type"`
-	URL  string `json:"url"`
+	Type      string `json:"type"`
+	URL       string `json:"url"`
+	Directory string `json:"directory"`
 }
 
 type DependencyAuthor struct {
@@ -68,6 +111,9 @@ var regexpGoImport = []*regexp.Regexp{
 	regexp.MustCompile(`(?i)<\s*meta\s*content\s*=\s*"(?P<import_prefix>\S+)\s+(?P<vcs>\S+)\s+(?P<repo_root>\S+)"\s*name\s*=\s*"go-import"\s*/?>`),
 }
 
+// regexpMajorVersion matches the major version suffix of a Go module path.
+var regexpMajorVersion = regexp.MustCompile(`^v[2-9][0-9]*$`)
+
 // var regexPythonDep = regexp.MustCompile(`^#\s*[R|r]epo(sitory)*:\s*(?P<url>https:\/\/github.com\/(?P<full_name>.*\/(?P<name>.*)))`)
 
 type GoImport struct {
@@ -76,50 +122,82 @@ type GoImport struct {
 	RepoRoot     string
 }
 
-func (d *Dependency) PopulateLicence() string {
-	url := ""
-	content := ""
-	if d.HomePage != "" && strings.Contains(d.HomePage, "github.com") {
+// PopulateLicence fetches the license text of the dependency from its code
+// host, searching the directory of the module first.
+func (d *Dependency) PopulateLicence(ctx context.Context, config *Config) string {
+	url := d.Repository.URL
+	provider, ok := config.HostProvider(url)
+	if !ok {
 		url = d.HomePage
-	} else if d.Repository.URL != "" {
-		url = d.Repository.URL
-	}
-	if url != "" {
-		prefix := ""
-		parts := strings.FieldsFunc(url, func(r rune) bool {
-			return r == '/' || r == '#' || r == '.'
-		})
-
-		for i := 0; i < len(parts); i++ {
-			if parts[i] == "com" && parts[i-1] == "github" {
-				prefix = fmt.Sprintf("%s/%s", parts[i+1], parts[i+2])
-				break
-			}
-		}
-		if prefix != "" {
-			data, err := HTTPGet("https://raw.githubusercontent.com/" + prefix + "/HEAD/LICENSE.txt")
-			if err != nil {
-				data, err = HTTPGet("https://raw.githubusercontent.com/" + prefix + "/HEAD/LICENSE.md")
-				if err != nil {
-					data, err = HTTPGet("https://raw.githubusercontent.com/" + prefix + "/HEAD/LICENSE")
-				}
-			}
-			if err == nil {
-				content = data
-			}
+		if provider, ok = config.HostProvider(url); !ok {
+			return "\n\n"
 		}
 	}
+	content, licenseID, err := FindLicense(ctx, provider, url, d.ModuleDirs())
+	if err != nil {
+		log.Printf("No license file found for %s: %v", d.Name, err)
+	}
+	if d.LicenseID == "" {
+		d.LicenseID = licenseID
+	}
 	return fmt.Sprintf("%s\n\n", content)
 }
 
-func (d *Dependency) NpmLoad() error {
-	data, err := HTTPGet("https://registry.npmjs.org/" + d.Name)
+// PopulateNotice fetches the NOTICE file of the dependency from its code host,
+// at the pinned version.
+func (d *Dependency) PopulateNotice(ctx context.Context, config *Config) string {
+	url := d.Repository.URL
+	provider, ok := config.HostProvider(url)
+	if !ok {
+		url = d.HomePage
+		if provider, ok = config.HostProvider(url); !ok {
+			return ""
+		}
+	}
+	notice, err := FindNotice(ctx, provider, url, d.Revision(), d.ModuleDirs())
+	if err != nil {
+		log.Printf("NOTICE file load failed for %s: %v", d.Name, err)
+	}
+	return notice
+}
+
+// ModuleDirs returns the directories of the repository holding the dependency
+// when it is not at its root: the directory of a Go module, with and without
+// its major version suffix, or the directory of an npm package.
+func (d *Dependency) ModuleDirs() []string {
+	if dir := strings.Trim(d.Repository.Directory, "/"); dir != "" {
+		return []string{dir}
+	}
+	root, ok := RepositoryRoot(d.Repository.URL)
+	if !ok || d.DependencyType != GoDep {
+		return nil
+	}
+	prefix := root + "/"
+	if len(d.FullName) <= len(prefix) || !strings.EqualFold(d.FullName[:len(prefix)], prefix) {
+		return nil
+	}
+	dir := d.FullName[len(prefix):]
+	dirs := []string{dir}
+	if parent, major := path.Split(dir); parent != "" && regexpMajorVersion.MatchString(major) {
+		dirs = append(dirs, strings.TrimSuffix(parent, "/"))
+	}
+	return dirs
+}
+
+func (d *Dependency) NpmLoad(ctx context.Context) error {
+	data, err := HTTPGet(ctx, "https://registry.npmjs.org/"+d.Name)
 
 	if err != nil {
 		return err
 	}
 
-	if err := json.Unmarshal([]byte(data), &d); err != nil {
+	return d.unmarshalNpm([]byte(data))
+}
+
+// unmarshalNpm loads a registry document or package.json, where author and
+// repository may be plain strings and the license may use the legacy fields.
+func (d *Dependency) unmarshalNpm(data []byte) error {
+	if err := json.Unmarshal(data, &d); err != nil {
 		if ue, ok := err.(*json.UnmarshalTypeError); ok {
 			switch ue.Field {
 			case "Author":
@@ -133,84 +211,140 @@ func (d *Dependency) NpmLoad() error {
 					URL:  ue.Value,
 				}
 			}
-			return nil
+			return d.unmarshalNpmLicenses(data)
 		}
 		return err
 
 	}
 
-	return json.Unmarshal([]byte(data), &d)
+	if err := json.Unmarshal(data, &d); err != nil {
+		return err
+	}
+	return d.unmarshalNpmLicenses(data)
 }
 
-func (d *Dependency) LoadFromGithub(config *Config) error {
-	if strings.Contains(d.Repository.URL, "github.com") {
-		var gh = github.NewClient(nil)
-		repoDef := strings.Split(d.Repository.URL, "/")
-		scope := repoDef[len(repoDef)-2]
-		repoName := strings.ReplaceAll(repoDef[len(repoDef)-1], ".git", "")
-
-		if len(config.GHToken) != 0 {
-			ctx := context.Background()
-			ts := oauth2.StaticTokenSource(
-				&oauth2.Token{AccessToken: config.GHToken},
-			)
-			tc := oauth2.NewClient(ctx, ts)
-			gh = github.NewClient(tc)
-		}
-
-		repo, _, err := gh.Repositories.Get(context.Background(), scope, repoName)
-		if err != nil {
-			log.Printf("Github Load Failed  %v", err)
-			return err
-		}
-		if repo.Description != nil {
-			d.Description = *repo.Description
-		}
-		authorName := *repo.Owner.Login
-		author, _, err := gh.Users.Get(context.Background(), authorName)
-		if err == nil {
-			if author.Name != nil {
-				authorName = *author.Name
-			}
-		}
-		d.Author = DependencyAuthor{Name: authorName}
-
-		if len(repo.GetHomepage()) == 0 {
-			d.HomePage = repo.GetHTMLURL()
-		} else {
-			d.HomePage = repo.GetHomepage()
-		}
-
-		if repo.GetLicense() != nil {
-			d.License = repo.License.GetName()
-		} else {
-			log.Printf("There is no licence available for %s", d.Name)
-		}
+// LoadFromHost loads the author, description, homepage and license of the
+// dependency from the API of its code host. errNoHostProvider is returned for
+// repositories on unsupported hosts.
+func (d *Dependency) LoadFromHost(ctx context.Context, config *Config) error {
+	if repo, ok := config.GitHub.Lookup(d.Repository.URL, d.Revision()); ok {
+		repo.Apply(d)
+		return nil
+	}
+	provider, ok := config.HostProvider(d.Repository.URL)
+	if !ok {
+		return fmt.Errorf("%w for %s", errNoHostProvider, d.Repository.URL)
+	}
+	repo, err := provider.Repository(ctx, d.Repository.URL)
+	if err != nil {
+		log.Printf("Repository load failed  %v", err)
+		return err
 	}
+	repo.Apply(d)
 	return nil
 }
 
-func (d *Dependency) Generate(config *Config) error {
-	filename := GenerateFileName(d.Name)
+func (d *Dependency) Generate(ctx context.Context, config *Config) (err error) {
+	filename := d.FileName()
+	ctx = WithCacheKey(ctx, d.DependencyType.String(), d.Identity(), d.Version)
 
-	if err := MoveExistingNotice(config, filename); err != nil {
+	err = errRefresh
+	if reason := config.RefreshReason(d); reason != "" {
+		log.Printf("Refreshing the stanza of %s: %s", d.Name, reason)
+		ctx = WithCacheRevalidation(ctx)
+		defer func() { err = d.refreshed(config, reason, err) }()
+	} else {
+		err = d.ReuseExistingNotice(config)
+	}
+	if err != nil {
 		switch d.DependencyType {
 		case JsDep:
 			log.Printf("Generating notice for %s npm dependency from NPM registry", d.Name)
-			if err = d.NpmLoad(); err != nil {
+			err = d.NpmLoad(ctx)
+			if config.Offline {
+				err = d.loadOffline(config, err)
+			}
+			if err != nil {
 				log.Printf("NPM load failed  %s", d.Name)
 				return err
 			}
 		case GoDep:
-			log.Printf("Generating notice for %s go.mod dependency from Github", d.Name)
-			if err = d.LoadFromGithub(config); err != nil {
-				log.Printf("GitHub load failed  %s", d.Name)
+			log.Printf("Generating notice for %s go.mod dependency from %s", d.Name, d.Repository.URL)
+			err = d.LoadFromHost(ctx, config)
+			if errors.Is(err, errNoHostProvider) {
+				err = d.LoadFromClone(ctx, config)
+			}
+			if config.Offline {
+				err = d.loadOffline(config, err)
+			}
+			if err != nil {
+				log.Printf("Repository load failed  %s", d.Name)
 				return err
 			}
+		case ContainerDep:
+			log.Printf("Generating notice for %s container component", d.Name)
+		case VendoredDep:
+			log.Printf("Generating notice for %s vendored in %s", d.Name, d.Location)
+		case ManualDep:
+			log.Printf("Generating notice for %s from configuration", d.Name)
 		default:
 			return fmt.Errorf("unsupported dependency type for %s. Please add the notice stanza manually, before running this program", d.Name)
 		}
 
+		override, hasOverride := config.OverrideFor(d)
+		if hasOverride {
+			log.Printf("Applying configured overrides to %s", d.Name)
+			override.Apply(d)
+		}
+
+		if err = d.LoadLicenseText(ctx, config); err != nil {
+			log.Printf("License text load failed  %s", d.Name)
+			return err
+		}
+		d.parseLicenseExpression(config.LicensePreference)
+		if d.LicenseText == "" {
+			d.LicenseText = strings.TrimSpace(d.PopulateLicence(ctx, config))
+		}
+		d.LicenseText = NormaliseLicenseText(d.LicenseText, config.LicenseWrapWidth)
+		if len(d.Copyrights) == 0 {
+			d.Copyrights = ExtractCopyrights(d.LicenseText)
+		}
+		// Every license of an AND needs its text, while the repository
+		// often holds only one of them, or none when the file was not found
+		if licenses := d.ApplicableLicenses(); d.LicenseText == "" || len(licenses) > 1 {
+			for _, license := range licenses {
+				if d.LicenseText != "" && !LicenseTextMissing(d.LicenseText, license) {
+					continue
+				}
+				if text, ok := RenderStandardLicense(license, d.Copyrights); ok {
+					log.Printf("Using the standard %s text for %s", license, d.Name)
+					d.LicenseText = strings.TrimSpace(d.LicenseText + "\n\n" + NormaliseLicenseText(text, config.LicenseWrapWidth))
+					d.StandardLicenses = append(d.StandardLicenses, license)
+				} else if !config.Offline && CanonicalLicenseID(license) != "NOASSERTION" {
+					// The stanza names a license without its terms
+					log.Printf("No text available for the %s license of %s", license, d.Name)
+					config.Report.AddMissingLicenseText(d.Name, d.DependencyType, license)
+				}
+			}
+			if config.Offline && d.LicenseText == "" {
+				config.Report.AddFailure(d.Name, d.DependencyType, StageOffline, fmt.Errorf("no license text available offline"))
+			}
+		}
+		author := d.Author.Name
+		// The copyright holders are a better attribution than the package
+		// author or the repository owner, unless the author is configured
+		if holders := FormatHolders(d.Copyrights); holders != "" && override.Author == "" {
+			author = holders
+		}
+
+		// The appendix holds the terms shared by several dependencies
+		licenseText := d.LicenseText
+		if config.LicenseAppendix && licenseText != "" {
+			if licenseText, err = d.licenseTextReference(config); err != nil {
+				return err
+			}
+		}
+
 		var out *os.File
 		var writer *bufio.Writer
 
@@ -223,8 +357,8 @@ func (d *Dependency) Generate(config *Config) error {
 		if _, err = writer.WriteString(fmt.Sprintf("## %s\n\n", d.Name)); err != nil {
 			log.Printf("Error while writing string %v", err)
 		}
-		if d.Author.Name != "" {
-			if _, err = writer.WriteString(fmt.Sprintf("This product contains '%s' by %s.\n\n", d.Name, d.Author.Name)); err != nil {
+		if author != "" {
+			if _, err = writer.WriteString(fmt.Sprintf("This product contains '%s' by %s.\n\n", d.Name, author)); err != nil {
 				log.Printf("Error while writing string %v", err)
 			}
 		} else {
@@ -237,21 +371,52 @@ func (d *Dependency) Generate(config *Config) error {
 				log.Printf("Error while writing string %v", err)
 			}
 		}
+		if config.ShowVersions && d.VersionLine() != "" {
+			if _, err = writer.WriteString(fmt.Sprintf("%s\n\n", d.VersionLine())); err != nil {
+				log.Printf("Error while writing string %v", err)
+			}
+		}
 		if d.HomePage != "" {
 			if _, err = writer.WriteString(fmt.Sprintf("* HOMEPAGE:\n  * %s\n\n", d.HomePage)); err != nil {
 				log.Printf("Error while writing string %v", err)
 			}
 		}
+		if d.Location != "" {
+			if _, err = writer.WriteString(fmt.Sprintf("* LOCATION: %s\n\n", d.Location)); err != nil {
+				log.Printf("Error while writing string %v", err)
+			}
+		}
 		if d.License != "" {
-			if _, err = writer.WriteString(fmt.Sprintf("* LICENSE: %s\n\n", d.License)); err != nil {
+			if _, err = writer.WriteString(fmt.Sprintf("* LICENSE: %s\n\n", d.LicenseLine())); err != nil {
+				log.Printf("Error while writing string %v", err)
+			}
+		}
+		if len(d.StandardLicenses) > 0 {
+			if _, err = writer.WriteString(fmt.Sprintf("* LICENSE TEXT: standard %s text, not verified against upstream\n\n", strings.Join(d.StandardLicenses, " and "))); err != nil {
 				log.Printf("Error while writing string %v", err)
 			}
 		}
-		if _, err = writer.WriteString(d.PopulateLicence()); err != nil {
+		if _, err = writer.WriteString(fmt.Sprintf("%s\n\n", licenseText)); err != nil {
 			log.Printf("Error while writing string %v", err)
 		}
+		if d.NoticeText == "" && d.IsApacheLicensed() {
+			d.NoticeText = d.PopulateNotice(ctx, config)
+			if d.NoticeText == "" {
+				config.Report.AddMissingNotice(d.Name, d.DependencyType)
+			}
+		}
+		if d.NoticeText != "" {
+			if _, err = writer.WriteString(fmt.Sprintf("* NOTICE:\n\n%s\n\n", indentText(d.NoticeText, "    "))); err != nil {
+				log.Printf("Error while writing string %v", err)
+			}
+		}
 		writer.Flush()
 		out.Close()
+		entry := d.lockEntry()
+		if hasOverride {
+			entry.OverrideHash = override.Hash()
+		}
+		config.Lock.Record(entry)
 
 	} else {
 		log.Printf("Using existing notice for %s dependency", d.Name)
@@ -259,8 +424,47 @@ func (d *Dependency) Generate(config *Config) error {
 	return nil
 }
 
+// indentText indents the non empty lines of text, so a NOTICE file is kept
+// apart from the stanza and its lines are never taken for a stanza title or
+// separator.
+func indentText(text, indent string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			lines[i] = indent + strings.TrimRight(line, " \t\r")
+		} else {
+			lines[i] = ""
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// LoadLicenseText reads the license text from the configured license file,
+// relative to the repository, or URL.
+func (d *Dependency) LoadLicenseText(ctx context.Context, config *Config) error {
+	switch {
+	case d.LicenseFile != "":
+		licenseFile := d.LicenseFile
+		if !filepath.IsAbs(licenseFile) {
+			licenseFile = filepath.Join(config.Path, licenseFile)
+		}
+		data, err := os.ReadFile(licenseFile)
+		if err != nil {
+			return err
+		}
+		d.LicenseText = strings.TrimSpace(string(data))
+	case d.LicenseURL != "":
+		data, err := HTTPGet(ctx, d.LicenseURL)
+		if err != nil {
+			return err
+		}
+		d.LicenseText = strings.TrimSpace(data)
+	}
+	return nil
+}
+
 func (d *Dependency) Load(config *Config) string {
-	filename := GenerateFileName(d.Name)
+	filename := d.FileName()
 	c, _ := os.ReadFile(config.NoticeWorkPath() + "/" + filename)
 	return string(c)
 }
@@ -269,23 +473,30 @@ func (c *Config) PopulateJSDependencies(packageJSON string) ([]Dependency, error
 
 	o, err := os.ReadFile(packageJSON)
 	if err != nil {
-		log.Fatalf("%s-Invalid package json %v", packageJSON, err)
+		return nil, err
 	}
 	var npmPack NpmPackage
 
 	if err := json.Unmarshal(o, &npmPack); err != nil {
-		log.Fatalf("%s-Invalid package json %v", packageJSON, err)
+		return nil, fmt.Errorf("invalid package json %s: %w", packageJSON, err)
 	}
 
 	var npmDependencies Dependencies
+	locked := npmLockedVersions(packageJSON)
 
-	for dependency := range npmPack.Dependencies {
-		npmDependencies.append(Dependency{Name: dependency, DependencyType: JsDep})
+	for dependency, version := range npmPack.Dependencies {
+		if v, ok := locked[dependency]; ok {
+			version = v
+		}
+		npmDependencies.append(Dependency{Name: dependency, Version: version, Manifest: packageJSON, DependencyType: JsDep})
 	}
 
 	if c.IncludeDevDependencies {
-		for dependency := range npmPack.DevDependencies {
-			npmDependencies.append(Dependency{Name: dependency, DependencyType: JsDep})
+		for dependency, version := range npmPack.DevDependencies {
+			if v, ok := locked[dependency]; ok {
+				version = v
+			}
+			npmDependencies.append(Dependency{Name: dependency, Version: version, Manifest: packageJSON, DependencyType: JsDep})
 		}
 	}
 
@@ -310,117 +521,114 @@ func parseGoImport(data string) (GoImport, bool) {
 	return GoImport{}, false
 }
 
-func (c *Config) PopulateGoDependencies(goModFile string) ([]Dependency, error) {
+// goRepository is the module path of the Go dependency added for every go.mod
+// file, which is not a module of the module cache.
+const goRepository = "github.com/golang/go"
+
+func (c *Config) PopulateGoDependencies(ctx context.Context, goModFile string) ([]Dependency, error) {
 	var goDependencies Dependencies
 
+	o, err := os.ReadFile(goModFile)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := modfile.Parse(goModFile, o, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid go.mod file: %w", err)
+	}
+
+	var goVersion string
+	if f.Go != nil {
+		goVersion = f.Go.Version
+	}
 	goDependencies.append(Dependency{
 		Name:           "Go",
-		FullName:       "github.com/golang/go",
+		FullName:       goRepository,
 		HomePage:       "https://go.dev/",
 		Description:    "The Go programming language",
 		Author:         DependencyAuthor{Name: "The Go authors"},
 		License:        "BSD-style",
+		Version:        goVersion,
 		DependencyType: GoDep,
 		Repository: DependencyRepository{
 			Type: "git",
-			URL:  "github.com/golang/go",
+			URL:  goRepository,
 		},
 	})
-	o, e := os.ReadFile(goModFile)
-	if e != nil {
-		log.Fatalf("Could not read go.mod file at location %s . Error:  %v", goModFile, e)
-	}
 
-	f, err := modfile.Parse("go.mod", o, nil)
-	if err != nil {
-		log.Fatalf("Invalid go.mod file. %v", err)
-	}
-
-	var wg sync.WaitGroup
-
-	for _, r := range f.Require {
-		wg.Add(1)
-		r := r
-
-		go func() {
-			defer wg.Done()
-
-			log.Printf("Populating %s go.mod dependency", r.Mod.String())
-			if !r.Indirect {
-				var data string
-				var ok bool
-
-				data, err = HTTPGet(fmt.Sprintf("https://%s?go-get=1", r.Mod.Path))
-				if err != nil {
-					parts := strings.Split(r.Mod.Path, "/")
-					if len(parts) > 3 {
-						moduleroot := strings.Join(parts[:3], "/")
-						data, _ = HTTPGet(fmt.Sprintf("https://%s?go-get=1", moduleroot))
-					}
+	err = ForEach(ctx, c.Concurrency, f.Require, func(ctx context.Context, r *modfile.Require) {
+		log.Printf("Populating %s go.mod dependency", r.Mod.String())
+		if !r.Indirect {
+			ctx := WithCacheKey(ctx, GoDep.String(), Purl(purlTypes[GoDep], r.Mod.Path), r.Mod.Version)
+			data, err := HTTPGet(ctx, fmt.Sprintf("https://%s?go-get=1", r.Mod.Path))
+			if err != nil {
+				parts := strings.Split(r.Mod.Path, "/")
+				if len(parts) > 3 {
+					moduleroot := strings.Join(parts[:3], "/")
+					data, _ = HTTPGet(ctx, fmt.Sprintf("https://%s?go-get=1", moduleroot))
 				}
+			}
 
-				gi, ok := parseGoImport(data)
-				if !ok {
-					log.Printf("unrecognised import %q (no go-import meta tags)", r.Mod.Path)
-				} else {
-					p := strings.Split(gi.ImportPrefix, "/")
-					name := gi.ImportPrefix
-					l := len(p)
-					if l >= 2 {
-						name = p[l-2] + "/" + p[l-1]
-					}
-					if strings.HasPrefix(gi.RepoRoot, "https://go.googlesource.com/") {
-						parts := strings.Split(gi.RepoRoot, "/")
-						gi.RepoRoot = fmt.Sprintf("https://github.com/golang/%s", parts[len(parts)-1])
-					} else if strings.HasPrefix(name, "gopkg.in") {
-						p := strings.Split(name, "/")
-						p = strings.Split(p[1], ".")
-						name = fmt.Sprintf("go-%s/%s", p[0], p[0])
-						gi.RepoRoot = "https://github.com/" + name
-					}
-					goDependencies.append(Dependency{
-						Name:           name,
-						FullName:       gi.ImportPrefix,
-						DependencyType: GoDep,
-						Repository: DependencyRepository{
-							Type: gi.Vcs,
-							URL:  gi.RepoRoot,
-						},
-					})
+			gi, ok := parseGoImport(data)
+			if !ok && c.Offline {
+				gi, ok = guessGoImport(r.Mod.Path), true
+			}
+			if !ok {
+				log.Printf("unrecognised import %q (no go-import meta tags)", r.Mod.Path)
+				c.Report.AddFailure(r.Mod.Path, GoDep, StageResolve, fmt.Errorf("unrecognised import (no go-import meta tags)"))
+			} else {
+				p := strings.Split(gi.ImportPrefix, "/")
+				name := gi.ImportPrefix
+				l := len(p)
+				if l >= 2 {
+					name = p[l-2] + "/" + p[l-1]
+				}
+				if strings.HasPrefix(gi.RepoRoot, "https://go.googlesource.com/") {
+					parts := strings.Split(gi.RepoRoot, "/")
+					gi.RepoRoot = fmt.Sprintf("https://github.com/golang/%s", parts[len(parts)-1])
+				} else if strings.HasPrefix(name, "gopkg.in") {
+					p := strings.Split(name, "/")
+					p = strings.Split(p[1], ".")
+					name = fmt.Sprintf("go-%s/%s", p[0], p[0])
+					gi.RepoRoot = "https://github.com/" + name
 				}
+				if c.DisplayNames == displayNamesFull {
+					name = r.Mod.Path
+				}
+				goDependencies.append(Dependency{
+					Name:           name,
+					FullName:       r.Mod.Path,
+					Version:        r.Mod.Version,
+					Manifest:       goModFile,
+					DependencyType: GoDep,
+					Repository: DependencyRepository{
+						Type: gi.Vcs,
+						URL:  gi.RepoRoot,
+					},
+				})
 			}
-		}()
+		}
+	})
+	if err != nil {
+		return goDependencies.value, err
 	}
 
-	wg.Wait()
-
 	return goDependencies.value, nil
 }
 
-func RemoveIgnoredDependencies(allDeps []Dependency, depsToIgnore []string) []Dependency {
-	var filteredDeps []Dependency
-	for i := 0; i < len(allDeps); i++ {
-		for _, dep := range depsToIgnore {
-			if allDeps[i].Name == dep {
-				allDeps[i].Name = ""
-			}
-		}
-	}
-	for _, dep := range allDeps {
-		if dep.Name != "" {
-			filteredDeps = append(filteredDeps, dep)
-		}
-	}
-	return filteredDeps
-}
-
-func PopulateDependencies(config *Config) ([]Dependency, error) {
+func PopulateDependencies(ctx context.Context, config *Config) ([]Dependency, error) {
 	var allDeps []Dependency
 
+	// A broken manifest is reported and the remaining ones are still processed
 	for _, modFile := range config.GoFiles {
-		d, err := config.PopulateGoDependencies(modFile)
+		d, err := config.PopulateGoDependencies(ctx, modFile)
+		if ctx.Err() != nil {
+			return allDeps, ctx.Err()
+		}
 		if err != nil {
-			return allDeps, err
+			log.Printf("Error while reading %s: %v", modFile, err)
+			config.Report.AddFailure(modFile, GoDep, StageManifest, err)
 		}
 		allDeps = append(allDeps, d...)
 	}
@@ -428,13 +636,52 @@ func PopulateDependencies(config *Config) ([]Dependency, error) {
 	for _, jsFile := range config.JSFIles {
 		d, err := config.PopulateJSDependencies(jsFile)
 		if err != nil {
-			return allDeps, err
+			log.Printf("Error while reading %s: %v", jsFile, err)
+			config.Report.AddFailure(jsFile, JsDep, StageManifest, err)
+		}
+		allDeps = append(allDeps, d...)
+	}
+
+	if config.ScanVendored {
+		d, err := config.PopulateVendoredDependencies()
+		if err != nil {
+			log.Printf("Error while scanning vendored code: %v", err)
+			config.Report.AddFailure(config.Path, VendoredDep, StageManifest, err)
 		}
 		allDeps = append(allDeps, d...)
 	}
+
+	var containerDeps []Dependency
+	for _, dockerFile := range config.DockerFiles {
+		d, err := config.PopulateDockerfileDependencies(dockerFile)
+		if err != nil {
+			log.Printf("Error while reading %s: %v", dockerFile, err)
+			config.Report.AddFailure(dockerFile, ContainerDep, StageManifest, err)
+		}
+		containerDeps = append(containerDeps, d...)
+	}
+
+	for _, image := range config.ContainerImages {
+		if !filepath.IsAbs(image) {
+			image = filepath.Join(config.Path, image)
+		}
+		d, err := config.PopulateImageDependencies(image)
+		if err != nil {
+			log.Printf("Error while reading %s: %v", image, err)
+			config.Report.AddFailure(image, ContainerDep, StageManifest, err)
+		}
+		containerDeps = append(containerDeps, d...)
+	}
+	allDeps = append(allDeps, containerDeps...)
+
 	for _, dep := range config.AdditionalDependencies {
-		allDeps = append(allDeps, Dependency{Name: dep})
+		allDeps = append(allDeps, dep.Dependency())
 	}
-	allDeps = RemoveIgnoredDependencies(allDeps, config.IgnoreDependencies)
+	// The same module, package or base image is usually required by several
+	// manifests or images, possibly in different versions
+	allDeps = GroupVersions(allDeps)
+	DisambiguateNames(allDeps)
+	allDeps, ignored := RemoveIgnoredDependencies(allDeps, config.IgnoreDependencies)
+	config.Report.AddIgnored(ignored...)
 	return allDeps, nil
 }
@@ -1,15 +1,18 @@
 package main
 
 import (
+	"context"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNpmLoadSuccess(t *testing.T) {
 	dep := Dependency{Name: "mattermost-client"}
-	err := dep.NpmLoad()
+	err := dep.NpmLoad(context.Background())
 
 	assert.Nil(t, err)
 	assert.Equal(t, "Andy Lo-A-Foe", dep.Author.Name)
@@ -23,14 +26,14 @@ func TestNpmLoadSuccess(t *testing.T) {
 
 func TestNpmLoadFailure(t *testing.T) {
 	dep := Dependency{Name: "invalid-npm-package"}
-	err := dep.NpmLoad()
+	err := dep.NpmLoad(context.Background())
 	assert.NotNil(t, err)
 }
 
 func TestPopulateLicenseByHomePage(t *testing.T) {
 	dep := Dependency{Name: "mattermost-client", HomePage: "https://github.com/loafoe/mattermost-client#readme"}
 
-	license := dep.PopulateLicence()
+	license := dep.PopulateLicence(context.Background(), &Config{})
 
 	assert.NotEmpty(t, license)
 }
@@ -38,7 +41,7 @@ func TestPopulateLicenseByHomePage(t *testing.T) {
 func TestPopulateLicenseByUrl(t *testing.T) {
 	dep := Dependency{Name: "mattermost-client", Repository: DependencyRepository{URL: "https://github.com/loafoe/mattermost-client"}}
 
-	license := dep.PopulateLicence()
+	license := dep.PopulateLicence(context.Background(), &Config{})
 
 	assert.NotEmpty(t, license)
 }
@@ -47,10 +50,42 @@ func TestIgnoreDependencies(t *testing.T) {
 	os.Args = append(os.Args, "-p=/tmp/test", "-t=token", "-c=testdata/dependency_test.yaml")
 
 	config := newConfig()
-	allDeps, err := PopulateDependencies(config)
+	allDeps, err := PopulateDependencies(context.Background(), config)
 	assert.NoError(t, err)
 	assert.Equal(t, 1, len(allDeps))
 	assert.Equal(t, "wix", allDeps[0].Name)
 
 	os.Args = os.Args[:len(os.Args)-3]
 }
+
+func TestLoadLicenseTextFromFile(t *testing.T) {
+	config := &Config{Path: t.TempDir()}
+	writeTestFile(t, filepath.Join(config.Path, "assets/LICENSE.txt"), "\nSIL Open Font License\n")
+
+	dep := Dependency{Name: "Inter font", LicenseFile: "assets/LICENSE.txt"}
+	assert.NoError(t, dep.LoadLicenseText(context.Background(), config))
+	assert.Equal(t, "SIL Open Font License", dep.LicenseText)
+
+	dep = Dependency{Name: "missing", LicenseFile: "assets/MISSING"}
+	assert.Error(t, dep.LoadLicenseText(context.Background(), config))
+}
+
+func TestPopulateDependenciesReportsBrokenManifests(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "package.json"), "{not json")
+	config := &Config{
+		Path:                   root,
+		GoFiles:                []string{filepath.Join(root, "go.mod")},
+		JSFIles:                []string{filepath.Join(root, "package.json")},
+		AdditionalDependencies: []AdditionalDependency{{Name: "wix"}},
+		Report:                 &Report{},
+	}
+
+	deps, err := PopulateDependencies(context.Background(), config)
+	assert.NoError(t, err)
+	assert.Equal(t, []Dependency{{Name: "wix"}}, deps)
+	require.Len(t, config.Report.Failures, 2)
+	assert.Equal(t, StageManifest, config.Report.Failures[0].Stage)
+	assert.Equal(t, "go", config.Report.Failures[0].Ecosystem)
+	assert.Equal(t, "npm", config.Report.Failures[1].Ecosystem)
+}
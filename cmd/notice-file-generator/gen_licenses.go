@@ -0,0 +1,140 @@
+//go:build ignore
+
+// gen_licenses writes the texts of the SPDX license list to licenses/spdx, for
+// the licenses without a text of their own in licenses. Run it with
+// go generate after changing spdxListVersion.
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	spdxListModule  = "github.com/spdx/license-list-data"
+	spdxListVersion = "v3.24.0+incompatible"
+
+	// standardCopyrightPlaceholder must match the constant of license.go.
+	standardCopyrightPlaceholder = "Copyright (c) <year> <copyright holders>"
+
+	wrapWidth = 80
+)
+
+// regexpCopyrightPlaceholder matches the copyright lines of the SPDX texts
+// whose year and holder are to be filled in, ie. "Copyright (c) <year> <owner>"
+// or "Copyright (C) YEAR by AUTHOR EMAIL".
+var regexpCopyrightPlaceholder = regexp.MustCompile(`^Copyright\s*(\([cC]\)|©)?\s*(?i:<year>|<yyyy>|year)(\s.*)?$`)
+
+type spdxLicense struct {
+	LicenseID  string `json:"licenseId"`
+	Deprecated bool   `json:"isDeprecatedLicenseId"`
+}
+
+func main() {
+	out, err := exec.Command("go", "mod", "download", "-json", spdxListModule+"@"+spdxListVersion).Output()
+	if err != nil {
+		log.Fatalf("Unable to download the SPDX license list: %v", err)
+	}
+	var module struct{ Dir string }
+	if err := json.Unmarshal(out, &module); err != nil {
+		log.Fatalf("Unable to read the SPDX license list module: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(module.Dir, "json", "licenses.json"))
+	if err != nil {
+		log.Fatalf("Unable to read the SPDX license list: %v", err)
+	}
+	var list struct {
+		Licenses []spdxLicense `json:"licenses"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		log.Fatalf("Unable to parse the SPDX license list: %v", err)
+	}
+
+	// The -only and -or-later variants share their text, under the identifier
+	// returned by CanonicalLicenseID.
+	sort.Slice(list.Licenses, func(i, j int) bool {
+		return list.Licenses[i].LicenseID < list.Licenses[j].LicenseID
+	})
+	sources := map[string]string{}
+	for _, l := range list.Licenses {
+		if l.Deprecated {
+			continue
+		}
+		id := strings.TrimSuffix(strings.TrimSuffix(l.LicenseID, "-only"), "-or-later")
+		if _, err := os.Stat(filepath.Join("licenses", id+".txt")); err == nil {
+			continue
+		}
+		if _, ok := sources[id]; ok && strings.HasSuffix(l.LicenseID, "-or-later") {
+			continue
+		}
+		sources[id] = l.LicenseID
+	}
+
+	dir := filepath.Join("licenses", "spdx")
+	if err := os.RemoveAll(dir); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Fatal(err)
+	}
+	for id, source := range sources {
+		text, err := os.ReadFile(filepath.Join(module.Dir, "text", source+".txt"))
+		if err != nil {
+			log.Fatalf("Unable to read the text of %s: %v", source, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, id+".txt"), []byte(formatLicense(string(text))), 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+	log.Printf("%d license texts written to %s", len(sources), dir)
+}
+
+// formatLicense replaces the copyright placeholder of the first lines by the
+// one filled in by RenderStandardLicense, and wraps the long lines of the SPDX
+// texts, which hold a paragraph each.
+func formatLicense(text string) string {
+	text = strings.ReplaceAll(strings.TrimPrefix(text, "\ufeff"), "\r\n", "\n")
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	var b strings.Builder
+	filled := false
+	for i, line := range lines {
+		line = strings.TrimRight(line, " \t")
+		if !filled && i < 10 && regexpCopyrightPlaceholder.MatchString(line) {
+			line = standardCopyrightPlaceholder
+			filled = true
+		}
+		writeWrapped(&b, line)
+	}
+	return b.String()
+}
+
+// writeWrapped writes a line wrapped at wrapWidth, its continuation lines
+// keeping its indentation. Shorter lines are written as is.
+func writeWrapped(b *strings.Builder, line string) {
+	if len(line) <= wrapWidth {
+		b.WriteString(line + "\n")
+		return
+	}
+	indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+	width := len(indent)
+	b.WriteString(indent)
+	for j, word := range strings.Fields(line) {
+		if j > 0 && width+1+len(word) > wrapWidth {
+			b.WriteString("\n" + indent)
+			width = len(indent)
+		} else if j > 0 {
+			b.WriteString(" ")
+			width++
+		}
+		b.WriteString(word)
+		width += len(word)
+	}
+	b.WriteString("\n")
+}
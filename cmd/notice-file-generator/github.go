@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/github"
+	"golang.org/x/oauth2"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultGitHubHost = "github.com"
+
+// GitHub holds the API clients of a run: one for github.com and, when an
+// Enterprise server is configured, one for the repositories it hosts.
+type GitHub struct {
+	public         *gitHubHost
+	enterprise     *gitHubHost
+	enterpriseHost string
+
+	mu           sync.Mutex
+	repositories map[string]*graphQLRepository
+}
+
+type gitHubHost struct {
+	client *github.Client
+	// graphQL is the GraphQL endpoint, which can only be used with a token
+	graphQL       string
+	authenticated bool
+}
+
+func authenticatedClient(client *http.Client, token string) *http.Client {
+	if token == "" {
+		return client
+	}
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
+	return &http.Client{Transport: &oauth2.Transport{Source: ts, Base: client.Transport}}
+}
+
+// NewGitHub creates the GitHub clients on top of the shared HTTP client. The
+// Enterprise client is only created when baseURL is set, its upload URL is
+// derived from baseURL when empty.
+func NewGitHub(client *http.Client, token, baseURL, uploadURL, enterpriseToken string) (*GitHub, error) {
+	g := &GitHub{public: &gitHubHost{
+		client:        github.NewClient(authenticatedClient(client, token)),
+		graphQL:       "https://api.github.com/graphql",
+		authenticated: token != "",
+	}}
+	if baseURL == "" {
+		return g, nil
+	}
+
+	base, err := url.Parse(baseURL)
+	if err != nil || base.Host == "" {
+		return nil, fmt.Errorf("invalid GitHub Enterprise URL %q", baseURL)
+	}
+	// The API of an Enterprise server is served under /api/v3
+	if strings.Trim(base.Path, "/") == "" {
+		base.Path = "/api/v3/"
+	}
+	if uploadURL == "" {
+		upload := *base
+		upload.Path = "/api/uploads/"
+		uploadURL = upload.String()
+	}
+	enterprise, err := github.NewEnterpriseClient(base.String(), uploadURL, authenticatedClient(client, enterpriseToken))
+	if err != nil {
+		return nil, err
+	}
+	graphQL := *base
+	graphQL.Path = "/api/graphql"
+	g.enterprise = &gitHubHost{client: enterprise, graphQL: graphQL.String(), authenticated: enterpriseToken != ""}
+	g.enterpriseHost = strings.ToLower(base.Host)
+	return g, nil
+}
+
+// hostFor returns the API of a repository host, or nil when it is neither
+// github.com nor the Enterprise server.
+func (g *GitHub) hostFor(host string) *gitHubHost {
+	switch {
+	case host == defaultGitHubHost:
+		return g.public
+	case g.enterprise != nil && host == g.enterpriseHost:
+		return g.enterprise
+	}
+	return nil
+}
+
+// ClientFor returns the client serving a repository URL, and whether the
+// repository is hosted on github.com or the Enterprise server at all.
+func (g *GitHub) ClientFor(repoURL string) (*github.Client, string, string, bool) {
+	host, owner, repo, ok := ParseRepositoryURL(repoURL)
+	if !ok {
+		return nil, "", "", false
+	}
+	if g == nil {
+		// Without a configured run, ie. in tests, github.com is used anonymously
+		if host != defaultGitHubHost {
+			return nil, "", "", false
+		}
+		return github.NewClient(httpClient), owner, repo, true
+	}
+	if h := g.hostFor(host); h != nil {
+		return h.client, owner, repo, true
+	}
+	return nil, "", "", false
+}
+
+// ParseRepositoryURL returns the host, owner and name of a repository from the
+// many forms found in manifests, see splitRepositoryURL.
+func ParseRepositoryURL(repoURL string) (host, owner, repo string, ok bool) {
+	host, segments, ok := splitRepositoryURL(repoURL)
+	if !ok {
+		return "", "", "", false
+	}
+	return host, segments[0], strings.TrimSuffix(segments[1], ".git"), true
+}
+
+// ResolveGitHubToken returns the token to use for a GitHub host, and where it
+// was found: the flag of the host, -t for github.com and -github-token for the
+// Enterprise server, the environment variables used by the gh CLI, or the
+// hosts.yml file written by `gh auth login`. The token of a host is never
+// sent to another one.
+func ResolveGitHubToken(host, flagToken string) (string, string) {
+	envVars := []string{"GH_TOKEN", "GITHUB_TOKEN"}
+	flagName := "-t"
+	if host != defaultGitHubHost {
+		envVars = []string{"GH_ENTERPRISE_TOKEN", "GITHUB_ENTERPRISE_TOKEN"}
+		flagName = "-github-token"
+	}
+	if flagToken != "" {
+		return flagToken, flagName
+	}
+	for _, name := range envVars {
+		if token := os.Getenv(name); token != "" {
+			return token, name
+		}
+	}
+	if token := ghCLIToken(host); token != "" {
+		return token, "gh CLI configuration"
+	}
+	return "", ""
+}
+
+// ghConfigDir returns the configuration directory of the gh CLI.
+func ghConfigDir() string {
+	if dir := os.Getenv("GH_CONFIG_DIR"); dir != "" {
+		return dir
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "gh")
+	}
+	if runtime.GOOS == "windows" {
+		if dir := os.Getenv("AppData"); dir != "" {
+			return filepath.Join(dir, "GitHub CLI")
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "gh")
+}
+
+// ghCLIToken reads the token of a host from the gh CLI hosts.yml. Tokens kept
+// in the system keyring by recent gh versions are not available there.
+func ghCLIToken(host string) string {
+	dir := ghConfigDir()
+	if dir == "" {
+		return ""
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "hosts.yml"))
+	if err != nil {
+		return ""
+	}
+	var hosts map[string]struct {
+		OAuthToken string `yaml:"oauth_token"`
+	}
+	if err = yaml.Unmarshal(data, &hosts); err != nil {
+		log.Printf("Invalid gh CLI configuration %s: %v", dir, err)
+		return ""
+	}
+	for name, h := range hosts {
+		if strings.EqualFold(name, host) {
+			return h.OAuthToken
+		}
+	}
+	return ""
+}
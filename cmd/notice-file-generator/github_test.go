@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRepositoryURL(t *testing.T) {
+	for _, repoURL := range []string{
+		"https://github.com/mattermost/mattermost",
+		"https://github.com/mattermost/mattermost.git",
+		"git+https://github.com/mattermost/mattermost.git",
+		"git+ssh://git@github.com/mattermost/mattermost.git",
+		"git://github.com/mattermost/mattermost.git",
+		"git@github.com:mattermost/mattermost.git",
+		"github:mattermost/mattermost",
+		"github.com/mattermost/mattermost",
+		"https://GitHub.com/mattermost/mattermost/tree/master/server#readme",
+	} {
+		host, owner, repo, ok := ParseRepositoryURL(repoURL)
+		assert.True(t, ok, repoURL)
+		assert.Equal(t, []string{"github.com", "mattermost", "mattermost"}, []string{host, owner, repo}, repoURL)
+	}
+
+	_, _, _, ok := ParseRepositoryURL("https://go.dev/")
+	assert.False(t, ok)
+	_, _, _, ok = ParseRepositoryURL("")
+	assert.False(t, ok)
+}
+
+func TestResolveGitHubToken(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GH_CONFIG_DIR", dir)
+	t.Setenv("GH_TOKEN", "")
+	t.Setenv("GITHUB_TOKEN", "")
+	t.Setenv("GH_ENTERPRISE_TOKEN", "")
+	t.Setenv("GITHUB_ENTERPRISE_TOKEN", "")
+
+	token, source := ResolveGitHubToken(defaultGitHubHost, "")
+	assert.Equal(t, "", token)
+	assert.Equal(t, "", source)
+
+	writeTestFile(t, filepath.Join(dir, "hosts.yml"), `github.com:
+    user: octocat
+    oauth_token: gho_cli
+    git_protocol: https
+GHE.example.com:
+    oauth_token: gho_enterprise
+`)
+	token, source = ResolveGitHubToken(defaultGitHubHost, "")
+	assert.Equal(t, "gho_cli", token)
+	assert.Equal(t, "gh CLI configuration", source)
+	token, _ = ResolveGitHubToken("ghe.example.com", "")
+	assert.Equal(t, "gho_enterprise", token)
+
+	t.Setenv("GITHUB_TOKEN", "ghp_env")
+	token, source = ResolveGitHubToken(defaultGitHubHost, "")
+	assert.Equal(t, "ghp_env", token)
+	assert.Equal(t, "GITHUB_TOKEN", source)
+
+	token, source = ResolveGitHubToken(defaultGitHubHost, "ghp_flag")
+	assert.Equal(t, "ghp_flag", token)
+	assert.Equal(t, "-t", source)
+
+	// The Enterprise server has its own flag and variables
+	t.Setenv("GH_CONFIG_DIR", t.TempDir())
+	token, source = ResolveGitHubToken("ghe.example.com", "")
+	assert.Equal(t, "", token)
+	assert.Equal(t, "", source)
+	t.Setenv("GH_ENTERPRISE_TOKEN", "ghp_enterprise")
+	token, source = ResolveGitHubToken("ghe.example.com", "")
+	assert.Equal(t, "ghp_enterprise", token)
+	assert.Equal(t, "GH_ENTERPRISE_TOKEN", source)
+	token, source = ResolveGitHubToken("ghe.example.com", "ghp_enterprise_flag")
+	assert.Equal(t, "ghp_enterprise_flag", token)
+	assert.Equal(t, "-github-token", source)
+}
+
+func TestNewGitHubEnterprise(t *testing.T) {
+	g, err := NewGitHub(http.DefaultClient, "", "https://ghe.example.com", "", "")
+	require.NoError(t, err)
+	assert.Equal(t, "https://ghe.example.com/api/v3/", g.enterprise.client.BaseURL.String())
+	assert.Equal(t, "https://ghe.example.com/api/uploads/", g.enterprise.client.UploadURL.String())
+
+	client, owner, repo, ok := g.ClientFor("https://ghe.example.com/platform/server.git")
+	assert.True(t, ok)
+	assert.Same(t, g.enterprise.client, client)
+	assert.Equal(t, "platform", owner)
+	assert.Equal(t, "server", repo)
+
+	client, _, _, ok = g.ClientFor("https://github.com/mattermost/mattermost")
+	assert.True(t, ok)
+	assert.Same(t, g.public.client, client)
+	assert.Equal(t, "https://api.github.com/", client.BaseURL.String())
+
+	_, _, _, ok = g.ClientFor("https://gitlab.com/gitlab-org/gitlab")
+	assert.False(t, ok)
+
+	_, err = NewGitHub(http.DefaultClient, "", "ghe.example.com", "", "")
+	assert.Error(t, err)
+}
+
+func TestLoadFromHostEnterprise(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer ghe-token", r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/api/v3/repos/platform/server":
+			_, _ = w.Write([]byte(`{"description": "Internal server", "html_url": "https://ghe.example.com/platform/server", "owner": {"login": "platform"}, "license": {"name": "MIT License"}}`))
+		case "/api/v3/users/platform":
+			_, _ = w.Write([]byte(`{"login": "platform", "name": "Platform Team"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	g, err := NewGitHub(server.Client(), "public-token", server.URL, "", "ghe-token")
+	require.NoError(t, err)
+	serverURL, _ := url.Parse(server.URL)
+
+	dep := Dependency{Name: "platform/server", Repository: DependencyRepository{URL: "https://" + serverURL.Host + "/platform/server"}, DependencyType: GoDep}
+	require.NoError(t, dep.LoadFromHost(context.Background(), &Config{GitHub: g}))
+	assert.Equal(t, "Internal server", dep.Description)
+	assert.Equal(t, "Platform Team", dep.Author.Name)
+	assert.Equal(t, "MIT License", dep.License)
+	assert.Equal(t, "https://ghe.example.com/platform/server", dep.HomePage)
+}
+
+func TestModuleDirs(t *testing.T) {
+	dep := Dependency{FullName: "github.com/org/repo/sub/module/v2", Repository: DependencyRepository{URL: "https://github.com/Org/Repo"}, DependencyType: GoDep}
+	assert.Equal(t, []string{"sub/module/v2", "sub/module"}, dep.ModuleDirs())
+
+	dep = Dependency{FullName: "github.com/org/repo/v3", Repository: DependencyRepository{URL: "https://github.com/org/repo"}, DependencyType: GoDep}
+	assert.Equal(t, []string{"v3"}, dep.ModuleDirs())
+
+	dep = Dependency{FullName: "github.com/org/repo", Repository: DependencyRepository{URL: "https://github.com/org/repo"}, DependencyType: GoDep}
+	assert.Empty(t, dep.ModuleDirs())
+
+	dep = Dependency{FullName: "golang.org/x/mod", Repository: DependencyRepository{URL: "https://github.com/golang/mod"}, DependencyType: GoDep}
+	assert.Empty(t, dep.ModuleDirs())
+
+	dep = Dependency{Name: "@babel/core", Repository: DependencyRepository{URL: "https://github.com/babel/babel.git", Directory: "packages/babel-core"}, DependencyType: JsDep}
+	assert.Equal(t, []string{"packages/babel-core"}, dep.ModuleDirs())
+}
+
+func TestGitHubRawFile(t *testing.T) {
+	useHostServer(t, map[string]string{
+		"raw.githubusercontent.com/org/repo/v1.0.0/sub/NOTICE": "Sub module notice",
+		"raw.githubusercontent.com/org/repo/HEAD/LICENSE":      "MIT License",
+	})
+	p := gitHubProvider{}
+
+	// File bodies are not read through the rate limited contents API
+	content, err := p.RawFile(context.Background(), "https://github.com/org/repo", "sub/NOTICE", "v1.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "Sub module notice", content)
+	content, err = p.RawFile(context.Background(), "https://github.com/org/repo", "LICENSE", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "MIT License", content)
+}
+
+// newLicenseServer serves the contents and license API of a GitHub Enterprise
+// server from a map of file paths to content.
+func newLicenseServer(files map[string]string, detected string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encode := func(content string) string { return base64.StdEncoding.EncodeToString([]byte(content)) }
+		switch {
+		case r.URL.Path == "/api/v3/repos/org/repo/license":
+			if detected == "" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"path": detected, "content": encode(files[detected]), "encoding": "base64", "license": map[string]any{"spdx_id": "MIT"}})
+		case strings.HasPrefix(r.URL.Path, "/api/v3/repos/org/repo/contents"):
+			p := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v3/repos/org/repo/contents"), "/")
+			if content, ok := files[p]; ok {
+				_ = json.NewEncoder(w).Encode(map[string]any{"type": "file", "name": path.Base(p), "path": p, "content": encode(content), "encoding": "base64"})
+				return
+			}
+			var entries []map[string]any
+			for name := range files {
+				if path.Dir(name) == p || (p == "" && path.Dir(name) == ".") {
+					entries = append(entries, map[string]any{"type": "file", "name": path.Base(name), "path": name})
+				}
+			}
+			if entries == nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(entries)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestPopulateLicenceDiscovery(t *testing.T) {
+	files := map[string]string{
+		"LICENSE":               "Apache License Version 2.0",
+		"sub/go.mod":            "module example.com/sub",
+		"sub/LICENSE-MIT":       "MIT License",
+		"sub/LICENSE-APACHE":    "Apache License",
+		"sub/license_test.go":   "package sub",
+		"other/COPYING":         "GPL",
+		"other/nested/README":   "readme",
+		"lowercase/licence.txt": "ISC License",
+	}
+	server := newLicenseServer(files, "LICENSE")
+	defer server.Close()
+	g, err := NewGitHub(server.Client(), "", server.URL, "", "token")
+	require.NoError(t, err)
+	config := &Config{GitHub: g}
+	repoURL := "https://" + strings.TrimPrefix(server.URL, "http://") + "/org/repo"
+	host := strings.TrimPrefix(repoURL, "https://")
+
+	// A module in a subdirectory, with its major version suffix
+	dep := Dependency{Name: "repo/sub", FullName: host + "/sub/v2", Repository: DependencyRepository{URL: repoURL}, DependencyType: GoDep}
+	assert.Equal(t, "Apache License\n\nMIT License\n\n", dep.PopulateLicence(context.Background(), config))
+
+	dep = Dependency{Name: "repo/lowercase", FullName: host + "/lowercase", Repository: DependencyRepository{URL: repoURL}, DependencyType: GoDep}
+	assert.Equal(t, "ISC License\n\n", dep.PopulateLicence(context.Background(), config))
+
+	// The license detected by GitHub at the root
+	dep = Dependency{Name: "org/repo", FullName: host, Repository: DependencyRepository{URL: repoURL}, DependencyType: GoDep}
+	assert.Equal(t, "Apache License Version 2.0\n\n", dep.PopulateLicence(context.Background(), config))
+	assert.Equal(t, "MIT", dep.LicenseID)
+
+	// Any license file at the root, when GitHub detected none
+	server = newLicenseServer(map[string]string{"COPYING": "GPL", "README.md": "readme"}, "")
+	defer server.Close()
+	g, err = NewGitHub(server.Client(), "", server.URL, "", "token")
+	require.NoError(t, err)
+	dep = Dependency{Name: "org/repo", HomePage: "https://" + strings.TrimPrefix(server.URL, "http://") + "/org/repo#readme", DependencyType: JsDep}
+	assert.Equal(t, "GPL\n\n", dep.PopulateLicence(context.Background(), &Config{GitHub: g}))
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// graphQLBatchSize is the number of repositories fetched by a single GraphQL
+// query, which keeps the query well below the node limit of the API.
+const graphQLBatchSize = 50
+
+// graphQLLicenseFiles are the license files fetched along with the repository
+// metadata, at the revision of the dependency, in order of preference. Other names, and the license files of modules in a
+// subdirectory, are found by PopulateLicence.
+var graphQLLicenseFiles = []string{"LICENSE", "LICENSE.txt", "LICENSE.md", "COPYING"}
+
+const graphQLRepositoryFields = `description homepageUrl url
+  owner { login ... on User { name } ... on Organization { name } }
+  licenseInfo { name spdxId }`
+
+type graphQLBlob struct {
+	Text string `json:"text"`
+}
+
+type graphQLRepository struct {
+	Description string `json:"description"`
+	HomepageURL string `json:"homepageUrl"`
+	URL         string `json:"url"`
+	Owner       struct {
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	} `json:"owner"`
+	LicenseInfo *struct {
+		Name   string `json:"name"`
+		SpdxID string `json:"spdxId"`
+	} `json:"licenseInfo"`
+	LicenseText string `json:"-"`
+}
+
+type graphQLRequest struct {
+	Query     string            `json:"query"`
+	Variables map[string]string `json:"variables"`
+}
+
+type repositoryRef struct {
+	key, owner, name, revision string
+}
+
+// Prefetch loads the metadata and license text of the GitHub hosted
+// dependencies with batched GraphQL queries, so LoadFromHost does not need
+// several REST calls for each of them. GraphQL requires a token, anonymous runs
+// and failed batches fall back to the REST API.
+func (g *GitHub) Prefetch(ctx context.Context, deps []Dependency) {
+	if g == nil {
+		return
+	}
+	batches := make(map[*gitHubHost][]repositoryRef)
+	seen := make(map[string]bool)
+	for _, d := range deps {
+		host, owner, name, ok := ParseRepositoryURL(d.Repository.URL)
+		if !ok {
+			continue
+		}
+		h := g.hostFor(host)
+		revision := d.Revision()
+		key := repositoryKey(host, owner, name, revision)
+		if h == nil || !h.authenticated || seen[key] {
+			continue
+		}
+		seen[key] = true
+		batches[h] = append(batches[h], repositoryRef{key: key, owner: owner, name: name, revision: revision})
+	}
+
+	for h, refs := range batches {
+		for start := 0; start < len(refs); start += graphQLBatchSize {
+			if ctx.Err() != nil {
+				return
+			}
+			end := min(start+graphQLBatchSize, len(refs))
+			repositories, err := h.queryRepositories(ctx, refs[start:end])
+			if err != nil {
+				log.Printf("GitHub GraphQL query failed, falling back to the REST API: %v", err)
+				continue
+			}
+			g.mu.Lock()
+			if g.repositories == nil {
+				g.repositories = make(map[string]*graphQLRepository)
+			}
+			for key, repo := range repositories {
+				g.repositories[key] = repo
+			}
+			g.mu.Unlock()
+		}
+	}
+}
+
+// Lookup returns the prefetched metadata of a repository at a tag or commit,
+// or "" for the default branch.
+func (g *GitHub) Lookup(repoURL, revision string) (*graphQLRepository, bool) {
+	if g == nil {
+		return nil, false
+	}
+	host, owner, name, ok := ParseRepositoryURL(repoURL)
+	if !ok {
+		return nil, false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	repo, ok := g.repositories[repositoryKey(host, owner, name, revision)]
+	return repo, ok
+}
+
+func repositoryKey(host, owner, name, revision string) string {
+	return strings.ToLower(host+"/"+owner+"/"+name) + "@" + revision
+}
+
+// queryRepositories fetches a batch of repositories, each one under its own
+// alias. Repositories which do not exist are left out of the result.
+func (h *gitHubHost) queryRepositories(ctx context.Context, refs []repositoryRef) (map[string]*graphQLRepository, error) {
+	var query, params strings.Builder
+	variables := make(map[string]string)
+	for i, ref := range refs {
+		revision := ref.revision
+		if revision == "" {
+			revision = "HEAD"
+		}
+		fmt.Fprintf(&params, "$o%d: String!, $n%d: String!, ", i, i)
+		fmt.Fprintf(&query, "r%d: repository(owner: $o%d, name: $n%d) { %s", i, i, i, graphQLRepositoryFields)
+		for j, file := range graphQLLicenseFiles {
+			fmt.Fprintf(&query, " l%d: object(expression: %q) { ... on Blob { text } }", j, revision+":"+file)
+		}
+		query.WriteString(" }\n")
+		variables[fmt.Sprintf("o%d", i)] = ref.owner
+		variables[fmt.Sprintf("n%d", i)] = ref.name
+	}
+	body := graphQLRequest{
+		Query:     fmt.Sprintf("query(%s) {\n%s}", strings.TrimSuffix(params.String(), ", "), query.String()),
+		Variables: variables,
+	}
+
+	req, err := h.client.NewRequest("POST", h.graphQL, body)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Data   map[string]json.RawMessage `json:"data"`
+		Errors []struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if _, err = h.client.Do(ctx, req, &resp); err != nil {
+		return nil, err
+	}
+	// Missing repositories are reported as NOT_FOUND errors next to the data
+	for _, e := range resp.Errors {
+		if e.Type != "NOT_FOUND" {
+			return nil, fmt.Errorf("%s", e.Message)
+		}
+	}
+
+	repositories := make(map[string]*graphQLRepository)
+	for i, ref := range refs {
+		data := resp.Data[fmt.Sprintf("r%d", i)]
+		var repo graphQLRepository
+		var fields map[string]json.RawMessage
+		if err = json.Unmarshal(data, &fields); err != nil || fields == nil {
+			continue
+		}
+		if err = json.Unmarshal(data, &repo); err != nil {
+			continue
+		}
+		for j := range graphQLLicenseFiles {
+			var blob graphQLBlob
+			if json.Unmarshal(fields[fmt.Sprintf("l%d", j)], &blob) == nil && strings.TrimSpace(blob.Text) != "" {
+				repo.LicenseText = blob.Text
+				break
+			}
+		}
+		repositories[ref.key] = &repo
+	}
+	return repositories, nil
+}
+
+// Apply copies the prefetched metadata to the fields of a dependency which the
+// package registry left empty.
+func (r *graphQLRepository) Apply(d *Dependency) {
+	if d.Description == "" {
+		d.Description = r.Description
+	}
+	if d.Author.Name == "" {
+		d.Author = DependencyAuthor{Name: r.Owner.Login}
+		if r.Owner.Name != "" {
+			d.Author.Name = r.Owner.Name
+		}
+	}
+	if d.HomePage == "" {
+		d.HomePage = r.HomepageURL
+	}
+	if d.HomePage == "" {
+		d.HomePage = r.URL
+	}
+	if r.LicenseInfo != nil && d.License == "" {
+		d.License = r.LicenseInfo.Name
+		d.LicenseID = r.LicenseInfo.SpdxID
+	}
+	// The license at the root may not be the one of a module in a subdirectory
+	if r.LicenseText != "" && d.LicenseText == "" && len(d.ModuleDirs()) == 0 {
+		d.LicenseText = strings.TrimSpace(r.LicenseText)
+	}
+}
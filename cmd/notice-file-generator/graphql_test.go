@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrefetchBatchesRepositories(t *testing.T) {
+	var queries []graphQLRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/graphql", r.URL.Path)
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		var req graphQLRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		queries = append(queries, req)
+
+		data := make(map[string]any)
+		var errors []map[string]any
+		for i := 0; i < len(req.Variables)/2; i++ {
+			name := req.Variables[fmt.Sprintf("n%d", i)]
+			alias := fmt.Sprintf("r%d", i)
+			if name == "missing" {
+				data[alias] = nil
+				errors = append(errors, map[string]any{"type": "NOT_FOUND", "path": []string{alias}, "message": "Could not resolve to a Repository"})
+				continue
+			}
+			data[alias] = map[string]any{
+				"description": "Repository " + name,
+				"homepageUrl": "",
+				"url":         "https://ghe.example.com/org/" + name,
+				"owner":       map[string]any{"login": "org", "name": "The Org"},
+				"licenseInfo": map[string]any{"name": "MIT License", "spdxId": "MIT"},
+				"l0":          nil,
+				"l1":          map[string]any{"text": "MIT License text of " + name},
+				"l2":          nil,
+				"l3":          nil,
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": data, "errors": errors})
+	}))
+	defer server.Close()
+
+	g, err := NewGitHub(server.Client(), "", server.URL, "", "token")
+	require.NoError(t, err)
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	var deps []Dependency
+	for i := 0; i < graphQLBatchSize+10; i++ {
+		deps = append(deps, Dependency{Name: fmt.Sprintf("org/repo%d", i), Repository: DependencyRepository{URL: fmt.Sprintf("https://%s/org/repo%d", host, i)}, DependencyType: GoDep})
+	}
+	deps = append(deps,
+		// The license of a pinned version is read at its tag
+		Dependency{Name: "org/pinned", FullName: host + "/org/pinned", Version: "v1.2.0", Repository: DependencyRepository{URL: fmt.Sprintf("https://%s/org/pinned", host)}, DependencyType: GoDep},
+		// Duplicates, missing, anonymous and non GitHub repositories are not queried
+		Dependency{Name: "org/repo0-again", Repository: DependencyRepository{URL: fmt.Sprintf("git+https://%s/org/repo0.git", host)}, DependencyType: GoDep},
+		Dependency{Name: "org/missing", Repository: DependencyRepository{URL: fmt.Sprintf("https://%s/org/missing", host)}, DependencyType: GoDep},
+		Dependency{Name: "public/repo", Repository: DependencyRepository{URL: "https://github.com/public/repo"}, DependencyType: GoDep},
+		Dependency{Name: "gitlab/repo", Repository: DependencyRepository{URL: "https://gitlab.com/gitlab/repo"}, DependencyType: GoDep},
+	)
+
+	g.Prefetch(context.Background(), deps)
+	require.Len(t, queries, 2)
+	assert.Len(t, queries[0].Variables, 2*graphQLBatchSize)
+	assert.Len(t, queries[1].Variables, 2*12)
+	assert.Contains(t, queries[0].Query, `"HEAD:LICENSE"`)
+	assert.Contains(t, queries[1].Query, `"v1.2.0:LICENSE"`)
+
+	dep := Dependency{Name: "org/repo3", Repository: DependencyRepository{URL: fmt.Sprintf("https://%s/org/repo3", host)}, DependencyType: GoDep}
+	require.NoError(t, dep.LoadFromHost(context.Background(), &Config{GitHub: g}))
+	assert.Equal(t, "Repository repo3", dep.Description)
+	assert.Equal(t, "The Org", dep.Author.Name)
+	assert.Equal(t, "https://ghe.example.com/org/repo3", dep.HomePage)
+	assert.Equal(t, "MIT License", dep.License)
+	assert.Equal(t, "MIT", dep.LicenseID)
+	assert.Equal(t, "MIT License text of repo3", dep.LicenseText)
+	assert.Len(t, queries, 2)
+
+	// The metadata of the package registry is kept
+	dep = Dependency{Name: "org/pinned", FullName: host + "/org/pinned", Version: "v1.2.0", Description: "From the registry", License: "Apache-2.0", Repository: DependencyRepository{URL: fmt.Sprintf("https://%s/org/pinned", host)}, DependencyType: GoDep}
+	require.NoError(t, dep.LoadFromHost(context.Background(), &Config{GitHub: g}))
+	assert.Equal(t, "From the registry", dep.Description)
+	assert.Equal(t, "The Org", dep.Author.Name)
+	assert.Equal(t, "Apache-2.0", dep.License)
+	assert.Equal(t, "MIT License text of pinned", dep.LicenseText)
+
+	_, ok := g.Lookup(fmt.Sprintf("https://%s/org/missing", host), "")
+	assert.False(t, ok)
+	_, ok = g.Lookup("https://github.com/public/repo", "")
+	assert.False(t, ok)
+	_, ok = g.Lookup(fmt.Sprintf("https://%s/org/pinned", host), "")
+	assert.False(t, ok)
+}
+
+func TestPrefetchFallsBackOnErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data": null, "errors": [{"type": "MAX_NODE_LIMIT_EXCEEDED", "message": "too many nodes"}]}`))
+	}))
+	defer server.Close()
+
+	g, err := NewGitHub(server.Client(), "", server.URL, "", "token")
+	require.NoError(t, err)
+	repoURL := "https://" + strings.TrimPrefix(server.URL, "http://") + "/org/repo"
+	g.Prefetch(context.Background(), []Dependency{{Name: "org/repo", Repository: DependencyRepository{URL: repoURL}}})
+	_, ok := g.Lookup(repoURL, "")
+	assert.False(t, ok)
+}
@@ -0,0 +1,672 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"gopkg.in/yaml.v3"
+)
+
+// Supported code host types, used as values of the hosts configuration.
+const (
+	hostGitLab    = "gitlab"
+	hostBitbucket = "bitbucket"
+	hostGitea     = "gitea"
+	hostSourcehut = "sourcehut"
+)
+
+// knownHosts are the public code hosts recognised without configuration.
+var knownHosts = map[string]string{
+	"gitlab.com":    hostGitLab,
+	"bitbucket.org": hostBitbucket,
+	"codeberg.org":  hostGitea,
+	"gitea.com":     hostGitea,
+	"git.sr.ht":     hostSourcehut,
+}
+
+// defaultTokenEnv are the environment variables holding the token of a
+// public host, when the configuration does not name one. Self-hosted servers
+// need an explicit tokenEnv, so a token is never sent to another server.
+var defaultTokenEnv = map[string]string{
+	"gitlab.com":    "GITLAB_TOKEN",
+	"bitbucket.org": "BITBUCKET_TOKEN",
+	"gitea.com":     "GITEA_TOKEN",
+}
+
+// commonLicenseFiles are probed on hosts which cannot list a directory.
+var commonLicenseFiles = []string{"LICENSE", "LICENSE.txt", "LICENSE.md", "LICENCE", "COPYING", "UNLICENSE"}
+
+// noticeFiles are probed on hosts which cannot list a directory.
+var noticeFiles = []string{"NOTICE", "NOTICE.txt", "NOTICE.md"}
+
+// errListingNotSupported is returned by hosts without a directory listing.
+var errListingNotSupported = errors.New("directory listing not supported")
+
+// HostConfig declares a self-hosted code host, ie. a GitLab or Gitea server,
+// and the environment variable holding its token. GitHub Enterprise is set
+// with the -github-url flag instead.
+type HostConfig struct {
+	Type     string `yaml:"type"`
+	TokenEnv string `yaml:"tokenEnv"`
+}
+
+func (h *HostConfig) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		h.Type = value.Value
+	} else {
+		type plain HostConfig
+		if err := value.Decode((*plain)(h)); err != nil {
+			return err
+		}
+	}
+	switch h.Type {
+	case hostGitLab, hostBitbucket, hostGitea, hostSourcehut:
+		return nil
+	}
+	return fmt.Errorf("line %d: unsupported host type %q", value.Line, h.Type)
+}
+
+// RepositoryInfo is the metadata of a repository returned by a code host.
+type RepositoryInfo struct {
+	Description string
+	Owner       string
+	HomePage    string
+	License     string
+	LicenseID   string
+}
+
+// Apply copies the repository metadata to a dependency.
+func (r RepositoryInfo) Apply(d *Dependency) {
+	if r.Description != "" {
+		d.Description = r.Description
+	}
+	if r.Owner != "" {
+		d.Author = DependencyAuthor{Name: r.Owner}
+	}
+	if r.HomePage != "" {
+		d.HomePage = r.HomePage
+	}
+	if r.License != "" {
+		d.License = r.License
+		d.LicenseID = r.LicenseID
+	} else {
+		log.Printf("There is no licence available for %s", d.Name)
+	}
+}
+
+// HostProvider reads repositories from the API of a code host.
+type HostProvider interface {
+	// Repository returns the metadata of a repository.
+	Repository(ctx context.Context, repoURL string) (RepositoryInfo, error)
+	// ListFiles returns the paths of the files directly in a directory, the
+	// root being "", at a tag or commit or "" for the default branch.
+	ListFiles(ctx context.Context, repoURL, dir, ref string) ([]string, error)
+	// RawFile returns the content of a file at a tag or commit, or "" for the
+	// default branch.
+	RawFile(ctx context.Context, repoURL, path, ref string) (string, error)
+}
+
+// licenseDetector is implemented by hosts detecting the license of a
+// repository, returning its text and SPDX identifier.
+type licenseDetector interface {
+	DetectLicense(ctx context.Context, repoURL string) (string, string, error)
+}
+
+// HostProvider returns the provider of the host of a repository URL: GitHub
+// and its Enterprise server, the known public hosts and the configured ones.
+func (c *Config) HostProvider(repoURL string) (HostProvider, bool) {
+	if _, _, _, ok := c.GitHub.ClientFor(repoURL); ok {
+		return gitHubProvider{c.GitHub}, true
+	}
+	host, _, ok := splitRepositoryURL(repoURL)
+	if !ok {
+		return nil, false
+	}
+	hc, ok := c.Hosts[host]
+	if !ok {
+		if hc.Type, ok = knownHosts[host]; !ok {
+			return nil, false
+		}
+	}
+	tokenEnv := hc.TokenEnv
+	if tokenEnv == "" {
+		tokenEnv = defaultTokenEnv[host]
+	}
+	token := ""
+	if tokenEnv != "" {
+		token = os.Getenv(tokenEnv)
+	}
+
+	switch hc.Type {
+	case hostGitLab:
+		return gitLabProvider{host: host, token: token}, true
+	case hostBitbucket:
+		return bitbucketProvider{token: token}, true
+	case hostGitea:
+		return giteaProvider{host: host, token: token}, true
+	case hostSourcehut:
+		return sourcehutProvider{host: host}, true
+	}
+	return nil, false
+}
+
+// splitRepositoryURL returns the host and the path segments of a repository
+// URL, ie. https://github.com/o/r.git, git+ssh://git@github.com/o/r,
+// git@github.com:o/r.git, github:o/r or github.com/o/r. GitLab projects may
+// have more than two segments.
+func splitRepositoryURL(repoURL string) (string, []string, bool) {
+	s := strings.TrimSpace(repoURL)
+	s = strings.TrimPrefix(s, "git+")
+	if rest, found := strings.CutPrefix(s, "github:"); found {
+		s = defaultGitHubHost + "/" + rest
+	}
+	if i := strings.Index(s, "://"); i >= 0 {
+		s = s[i+3:]
+	} else if at := strings.Index(s, "@"); at >= 0 && strings.Contains(s[at:], ":") {
+		// scp-like syntax: git@host:owner/repo
+		s = strings.Replace(s[at+1:], ":", "/", 1)
+	}
+	if at := strings.Index(s, "@"); at >= 0 && at < strings.Index(s+"/", "/") {
+		s = s[at+1:]
+	}
+	s, _, _ = strings.Cut(s, "#")
+	s, _, _ = strings.Cut(s, "?")
+
+	parts := strings.Split(strings.Trim(s, "/"), "/")
+	if len(parts) < 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return "", nil, false
+	}
+	host := strings.ToLower(parts[0])
+	// Web URLs of files and trees are cut at their route, ie. /-/tree or /src
+	segments := parts[1:]
+	for i, p := range segments {
+		if i >= 2 && (p == "-" || p == "tree" || p == "blob" || p == "src") {
+			segments = segments[:i]
+			break
+		}
+	}
+	segments[len(segments)-1] = strings.TrimSuffix(segments[len(segments)-1], ".git")
+	return host, segments, true
+}
+
+// RepositoryRoot returns host/path of a repository URL, ie. the import path of
+// a Go module at the root of the repository.
+func RepositoryRoot(repoURL string) (string, bool) {
+	host, segments, ok := splitRepositoryURL(repoURL)
+	if !ok {
+		return "", false
+	}
+	return host + "/" + strings.Join(segments, "/"), true
+}
+
+// FindLicense returns the license text of a repository. The module
+// directories, ie. the directory of a Go module or npm package in a monorepo,
+// are searched first, then the license detected by the host, then any license
+// file at the root of the repository.
+func FindLicense(ctx context.Context, p HostProvider, repoURL string, dirs []string) (string, string, error) {
+	for _, dir := range dirs {
+		if text, err := findLicenseFiles(ctx, p, repoURL, dir); err == nil && text != "" {
+			return text, "", nil
+		}
+	}
+
+	if detector, ok := p.(licenseDetector); ok {
+		if text, id, err := detector.DetectLicense(ctx, repoURL); err == nil && strings.TrimSpace(text) != "" {
+			return strings.TrimSpace(text), id, nil
+		}
+	}
+
+	text, err := findLicenseFiles(ctx, p, repoURL, "")
+	if err != nil {
+		return "", "", err
+	}
+	if text == "" {
+		return "", "", fmt.Errorf("no license file found in %s", repoURL)
+	}
+	return text, "", nil
+}
+
+// findLicenseFiles returns the concatenated license files of a repository
+// directory, like readLicenseFiles does for local directories. On hosts which
+// cannot list directories, the common license file names are probed.
+func findLicenseFiles(ctx context.Context, p HostProvider, repoURL, dir string) (string, error) {
+	var names []string
+	files, err := p.ListFiles(ctx, repoURL, dir, "")
+	switch {
+	case errors.Is(err, errListingNotSupported):
+		for _, name := range commonLicenseFiles {
+			names = append(names, joinRepositoryPath(dir, name))
+		}
+	case err != nil:
+		return "", err
+	default:
+		for _, f := range files {
+			if IsLicenseFile(f[strings.LastIndex(f, "/")+1:]) {
+				names = append(names, f)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	var texts []string
+	for _, name := range names {
+		content, err := p.RawFile(ctx, repoURL, name, "")
+		if IsNotFound(err) && files == nil {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		texts = append(texts, strings.TrimSpace(content))
+	}
+	return strings.Join(texts, "\n\n"), nil
+}
+
+// FindNotice returns the NOTICE file of a repository at a tag or commit,
+// searching the module directories first. When the revision does not exist
+// the default branch is used instead.
+func FindNotice(ctx context.Context, p HostProvider, repoURL, ref string, dirs []string) (string, error) {
+	for _, dir := range append(dirs, "") {
+		var names []string
+		files, err := p.ListFiles(ctx, repoURL, dir, ref)
+		switch {
+		case errors.Is(err, errListingNotSupported):
+			for _, name := range noticeFiles {
+				names = append(names, joinRepositoryPath(dir, name))
+			}
+		case dir == "" && ref != "" && IsNotFound(err):
+			log.Printf("Revision %s not found in %s, using the default branch", ref, repoURL)
+			return FindNotice(ctx, p, repoURL, "", dirs)
+		case err != nil:
+			if IsNotFound(err) {
+				continue
+			}
+			return "", err
+		default:
+			for _, f := range files {
+				if IsNoticeFile(f[strings.LastIndex(f, "/")+1:]) {
+					names = append(names, f)
+				}
+			}
+		}
+
+		for _, name := range names {
+			content, err := p.RawFile(ctx, repoURL, name, ref)
+			if IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return "", err
+			}
+			if text := strings.TrimSpace(content); text != "" {
+				return text, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+func joinRepositoryPath(dir, name string) string {
+	if dir == "" {
+		return name
+	}
+	return strings.TrimSuffix(dir, "/") + "/" + name
+}
+
+// getJSON decodes the JSON document at rsc, sent with the given headers.
+func getJSON(ctx context.Context, rsc string, header http.Header, v any) error {
+	data, err := HTTPGetWithHeader(ctx, rsc, header)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(data), v)
+}
+
+// gitHubProvider serves github.com and the Enterprise server through the
+// clients of the run.
+type gitHubProvider struct {
+	g *GitHub
+}
+
+func (p gitHubProvider) Repository(ctx context.Context, repoURL string) (RepositoryInfo, error) {
+	gh, owner, name, _ := p.g.ClientFor(repoURL)
+	repo, _, err := gh.Repositories.Get(ctx, owner, name)
+	if err != nil {
+		return RepositoryInfo{}, err
+	}
+	info := RepositoryInfo{
+		Description: repo.GetDescription(),
+		Owner:       repo.GetOwner().GetLogin(),
+		HomePage:    repo.GetHomepage(),
+		License:     repo.GetLicense().GetName(),
+		LicenseID:   repo.GetLicense().GetSPDXID(),
+	}
+	if author, _, err := gh.Users.Get(ctx, info.Owner); err == nil && author.GetName() != "" {
+		info.Owner = author.GetName()
+	}
+	if info.HomePage == "" {
+		info.HomePage = repo.GetHTMLURL()
+	}
+	return info, nil
+}
+
+func (p gitHubProvider) ListFiles(ctx context.Context, repoURL, dir, ref string) ([]string, error) {
+	gh, owner, name, _ := p.g.ClientFor(repoURL)
+	_, entries, _, err := gh.Repositories.GetContents(ctx, owner, name, dir, &github.RepositoryContentGetOptions{Ref: ref})
+	if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, e := range entries {
+		if e.GetType() == "file" {
+			files = append(files, e.GetPath())
+		}
+	}
+	return files, nil
+}
+
+// RawFile reads the files of github.com from raw.githubusercontent.com, which
+// is not rate limited like the REST API, and those of the Enterprise server
+// from its contents API.
+func (p gitHubProvider) RawFile(ctx context.Context, repoURL, path, ref string) (string, error) {
+	gh, owner, name, _ := p.g.ClientFor(repoURL)
+	if host, _, _, _ := ParseRepositoryURL(repoURL); host == defaultGitHubHost {
+		if ref == "" {
+			ref = "HEAD"
+		}
+		return HTTPGet(ctx, fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/%s/%s", owner, name, ref, path))
+	}
+	file, _, _, err := gh.Repositories.GetContents(ctx, owner, name, path, &github.RepositoryContentGetOptions{Ref: ref})
+	if err != nil {
+		return "", err
+	}
+	return file.GetContent()
+}
+
+// DetectLicense uses the license API, which returns the license file GitHub
+// detected at the root of the repository along with its SPDX identifier.
+func (p gitHubProvider) DetectLicense(ctx context.Context, repoURL string) (string, string, error) {
+	gh, owner, name, _ := p.g.ClientFor(repoURL)
+	license, _, err := gh.Repositories.License(ctx, owner, name)
+	if err != nil {
+		return "", "", err
+	}
+	content, err := (&github.RepositoryContent{Content: license.Content, Encoding: license.Encoding}).GetContent()
+	return content, license.GetLicense().GetSPDXID(), err
+}
+
+// gitLabProvider serves gitlab.com and self-hosted GitLab servers, whose
+// projects may be nested in groups.
+type gitLabProvider struct {
+	host  string
+	token string
+}
+
+func (p gitLabProvider) project(repoURL string) string {
+	_, segments, _ := splitRepositoryURL(repoURL)
+	return fmt.Sprintf("https://%s/api/v4/projects/%s", p.host, url.PathEscape(strings.Join(segments, "/")))
+}
+
+func (p gitLabProvider) header() http.Header {
+	if p.token == "" {
+		return nil
+	}
+	return http.Header{"Private-Token": {p.token}}
+}
+
+func (p gitLabProvider) Repository(ctx context.Context, repoURL string) (RepositoryInfo, error) {
+	var project struct {
+		Description string `json:"description"`
+		WebURL      string `json:"web_url"`
+		Namespace   struct {
+			Name string `json:"name"`
+		} `json:"namespace"`
+		License *struct {
+			Key  string `json:"key"`
+			Name string `json:"name"`
+		} `json:"license"`
+	}
+	if err := getJSON(ctx, p.project(repoURL)+"?license=true", p.header(), &project); err != nil {
+		return RepositoryInfo{}, err
+	}
+	info := RepositoryInfo{Description: project.Description, Owner: project.Namespace.Name, HomePage: project.WebURL}
+	if project.License != nil {
+		info.License = project.License.Name
+		info.LicenseID = project.License.Key
+	}
+	return info, nil
+}
+
+func (p gitLabProvider) ListFiles(ctx context.Context, repoURL, dir, ref string) ([]string, error) {
+	var entries []struct {
+		Path string `json:"path"`
+		Type string `json:"type"`
+	}
+	tree := p.project(repoURL) + "/repository/tree?per_page=100&path=" + url.QueryEscape(dir)
+	if ref != "" {
+		tree += "&ref=" + url.QueryEscape(ref)
+	}
+	if err := getJSON(ctx, tree, p.header(), &entries); err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, e := range entries {
+		if e.Type == "blob" {
+			files = append(files, e.Path)
+		}
+	}
+	return files, nil
+}
+
+func (p gitLabProvider) RawFile(ctx context.Context, repoURL, path, ref string) (string, error) {
+	if ref == "" {
+		ref = "HEAD"
+	}
+	return HTTPGetWithHeader(ctx, p.project(repoURL)+"/repository/files/"+url.PathEscape(path)+"/raw?ref="+url.QueryEscape(ref), p.header())
+}
+
+// bitbucketProvider serves Bitbucket Cloud.
+type bitbucketProvider struct {
+	token string
+}
+
+type bitbucketRepository struct {
+	Description string `json:"description"`
+	Website     string `json:"website"`
+	Owner       struct {
+		DisplayName string `json:"display_name"`
+	} `json:"owner"`
+	Links struct {
+		HTML struct {
+			Href string `json:"href"`
+		} `json:"html"`
+	} `json:"links"`
+	MainBranch struct {
+		Name string `json:"name"`
+	} `json:"mainbranch"`
+}
+
+func (p bitbucketProvider) repository(repoURL string) string {
+	_, segments, _ := splitRepositoryURL(repoURL)
+	return "https://api.bitbucket.org/2.0/repositories/" + segments[0] + "/" + segments[1]
+}
+
+func (p bitbucketProvider) header() http.Header {
+	if p.token == "" {
+		return nil
+	}
+	return http.Header{"Authorization": {"Bearer " + p.token}}
+}
+
+func (p bitbucketProvider) get(ctx context.Context, repoURL string) (bitbucketRepository, error) {
+	var repo bitbucketRepository
+	err := getJSON(ctx, p.repository(repoURL), p.header(), &repo)
+	return repo, err
+}
+
+func (p bitbucketProvider) Repository(ctx context.Context, repoURL string) (RepositoryInfo, error) {
+	repo, err := p.get(ctx, repoURL)
+	if err != nil {
+		return RepositoryInfo{}, err
+	}
+	info := RepositoryInfo{Description: repo.Description, Owner: repo.Owner.DisplayName, HomePage: repo.Website}
+	if info.HomePage == "" {
+		info.HomePage = repo.Links.HTML.Href
+	}
+	return info, nil
+}
+
+// src returns the URL of a path at a revision, or of the main branch as the
+// source API does not resolve HEAD.
+func (p bitbucketProvider) src(ctx context.Context, repoURL, path, ref string) (string, error) {
+	if ref == "" {
+		repo, err := p.get(ctx, repoURL)
+		if err != nil {
+			return "", err
+		}
+		ref = repo.MainBranch.Name
+	}
+	return p.repository(repoURL) + "/src/" + url.PathEscape(ref) + "/" + path, nil
+}
+
+func (p bitbucketProvider) ListFiles(ctx context.Context, repoURL, dir, ref string) ([]string, error) {
+	src, err := p.src(ctx, repoURL, joinRepositoryPath(dir, "")+"?pagelen=100", ref)
+	if err != nil {
+		return nil, err
+	}
+	var listing struct {
+		Values []struct {
+			Path string `json:"path"`
+			Type string `json:"type"`
+		} `json:"values"`
+	}
+	if err = getJSON(ctx, src, p.header(), &listing); err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, v := range listing.Values {
+		if v.Type == "commit_file" {
+			files = append(files, v.Path)
+		}
+	}
+	return files, nil
+}
+
+func (p bitbucketProvider) RawFile(ctx context.Context, repoURL, path, ref string) (string, error) {
+	src, err := p.src(ctx, repoURL, path, ref)
+	if err != nil {
+		return "", err
+	}
+	return HTTPGetWithHeader(ctx, src, p.header())
+}
+
+// giteaProvider serves Gitea and Forgejo servers, ie. Codeberg.
+type giteaProvider struct {
+	host  string
+	token string
+}
+
+func (p giteaProvider) repository(repoURL string) string {
+	_, segments, _ := splitRepositoryURL(repoURL)
+	return fmt.Sprintf("https://%s/api/v1/repos/%s/%s", p.host, segments[0], segments[1])
+}
+
+func (p giteaProvider) header() http.Header {
+	if p.token == "" {
+		return nil
+	}
+	return http.Header{"Authorization": {"token " + p.token}}
+}
+
+func (p giteaProvider) Repository(ctx context.Context, repoURL string) (RepositoryInfo, error) {
+	var repo struct {
+		Description string `json:"description"`
+		Website     string `json:"website"`
+		HTMLURL     string `json:"html_url"`
+		Owner       struct {
+			Login    string `json:"login"`
+			FullName string `json:"full_name"`
+		} `json:"owner"`
+		// Licenses are detected by Gitea 1.22 and later
+		Licenses []string `json:"licenses"`
+	}
+	if err := getJSON(ctx, p.repository(repoURL), p.header(), &repo); err != nil {
+		return RepositoryInfo{}, err
+	}
+	info := RepositoryInfo{Description: repo.Description, Owner: repo.Owner.FullName, HomePage: repo.Website}
+	if info.Owner == "" {
+		info.Owner = repo.Owner.Login
+	}
+	if info.HomePage == "" {
+		info.HomePage = repo.HTMLURL
+	}
+	if len(repo.Licenses) == 1 {
+		info.License = repo.Licenses[0]
+		info.LicenseID = repo.Licenses[0]
+	}
+	return info, nil
+}
+
+func (p giteaProvider) ListFiles(ctx context.Context, repoURL, dir, ref string) ([]string, error) {
+	var entries []struct {
+		Path string `json:"path"`
+		Type string `json:"type"`
+	}
+	if err := getJSON(ctx, p.repository(repoURL)+"/contents/"+dir+refQuery(ref), p.header(), &entries); err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, e := range entries {
+		if e.Type == "file" {
+			files = append(files, e.Path)
+		}
+	}
+	return files, nil
+}
+
+func (p giteaProvider) RawFile(ctx context.Context, repoURL, path, ref string) (string, error) {
+	return HTTPGetWithHeader(ctx, p.repository(repoURL)+"/raw/"+path+refQuery(ref), p.header())
+}
+
+func refQuery(ref string) string {
+	if ref == "" {
+		return ""
+	}
+	return "?ref=" + url.QueryEscape(ref)
+}
+
+// sourcehutProvider serves git.sr.ht, whose API requires an OAuth token: the
+// metadata is derived from the repository URL and files are read from the
+// repository web interface.
+type sourcehutProvider struct {
+	host string
+}
+
+func (p sourcehutProvider) Repository(ctx context.Context, repoURL string) (RepositoryInfo, error) {
+	_, segments, _ := splitRepositoryURL(repoURL)
+	return RepositoryInfo{
+		Owner:    strings.TrimPrefix(segments[0], "~"),
+		HomePage: fmt.Sprintf("https://%s/%s/%s", p.host, segments[0], segments[1]),
+	}, nil
+}
+
+func (p sourcehutProvider) ListFiles(ctx context.Context, repoURL, dir, ref string) ([]string, error) {
+	return nil, errListingNotSupported
+}
+
+func (p sourcehutProvider) RawFile(ctx context.Context, repoURL, path, ref string) (string, error) {
+	_, segments, _ := splitRepositoryURL(repoURL)
+	if ref == "" {
+		ref = "HEAD"
+	}
+	return HTTPGet(ctx, fmt.Sprintf("https://%s/%s/%s/blob/%s/%s", p.host, segments[0], segments[1], url.PathEscape(ref), path))
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// rewriteTransport sends every request to a test server, keeping the original
+// host in the Host header.
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (t rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Host = req.URL.Host
+	req.URL.Scheme = t.target.Scheme
+	req.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// useHostServer serves all code hosts from a handler, keyed by host and
+// escaped path.
+func useHostServer(t *testing.T, responses map[string]string) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Host + r.URL.EscapedPath()
+		if r.URL.RawQuery != "" {
+			key += "?" + r.URL.RawQuery
+		}
+		body, ok := responses[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Host == "gitlab.example.com" {
+			assert.Equal(t, "glpat-test", r.Header.Get("Private-Token"))
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	target, _ := url.Parse(server.URL)
+	useHTTPClient(t, &http.Client{Transport: rewriteTransport{target}})
+}
+
+func TestSplitRepositoryURL(t *testing.T) {
+	host, segments, ok := splitRepositoryURL("https://gitlab.com/group/subgroup/project.git")
+	assert.True(t, ok)
+	assert.Equal(t, "gitlab.com", host)
+	assert.Equal(t, []string{"group", "subgroup", "project"}, segments)
+
+	root, _ := RepositoryRoot("https://gitlab.com/group/subgroup/project/-/tree/main/sub")
+	assert.Equal(t, "gitlab.com/group/subgroup/project", root)
+	root, _ = RepositoryRoot("https://bitbucket.org/workspace/repo/src/main/")
+	assert.Equal(t, "bitbucket.org/workspace/repo", root)
+	root, _ = RepositoryRoot("git@codeberg.org:forgejo/forgejo.git")
+	assert.Equal(t, "codeberg.org/forgejo/forgejo", root)
+	root, _ = RepositoryRoot("https://git.sr.ht/~sircmpwn/getopt")
+	assert.Equal(t, "git.sr.ht/~sircmpwn/getopt", root)
+}
+
+func TestHostConfig(t *testing.T) {
+	var hosts map[string]HostConfig
+	require.NoError(t, yaml.Unmarshal([]byte("git.example.com: gitlab\ncode.example.org:\n  type: gitea\n  tokenEnv: EXAMPLE_TOKEN\n"), &hosts))
+	assert.Equal(t, map[string]HostConfig{
+		"git.example.com":  {Type: hostGitLab},
+		"code.example.org": {Type: hostGitea, TokenEnv: "EXAMPLE_TOKEN"},
+	}, hosts)
+
+	assert.ErrorContains(t, yaml.Unmarshal([]byte("git.example.com: svn\n"), &hosts), `unsupported host type "svn"`)
+}
+
+func TestHostProviderSelection(t *testing.T) {
+	t.Setenv("EXAMPLE_TOKEN", "secret")
+	t.Setenv("GITEA_TOKEN", "public")
+	config := &Config{Hosts: map[string]HostConfig{
+		"code.example.org": {Type: hostGitea, TokenEnv: "EXAMPLE_TOKEN"},
+		"git.example.org":  {Type: hostGitea},
+	}}
+
+	p, ok := config.HostProvider("https://github.com/mattermost/mattermost")
+	assert.True(t, ok)
+	assert.IsType(t, gitHubProvider{}, p)
+	p, _ = config.HostProvider("https://gitlab.com/gitlab-org/gitlab")
+	assert.IsType(t, gitLabProvider{}, p)
+	p, _ = config.HostProvider("https://bitbucket.org/workspace/repo")
+	assert.IsType(t, bitbucketProvider{}, p)
+	p, _ = config.HostProvider("https://codeberg.org/forgejo/forgejo")
+	assert.Equal(t, giteaProvider{host: "codeberg.org"}, p)
+	p, _ = config.HostProvider("https://git.sr.ht/~sircmpwn/getopt")
+	assert.IsType(t, sourcehutProvider{}, p)
+	p, _ = config.HostProvider("https://code.example.org/team/repo")
+	assert.Equal(t, giteaProvider{host: "code.example.org", token: "secret"}, p)
+
+	// The default token is only sent to the public host
+	p, _ = config.HostProvider("https://gitea.com/gitea/tea")
+	assert.Equal(t, giteaProvider{host: "gitea.com", token: "public"}, p)
+	p, _ = config.HostProvider("https://git.example.org/team/repo")
+	assert.Equal(t, giteaProvider{host: "git.example.org"}, p)
+
+	_, ok = config.HostProvider("https://go.googlesource.com/mod")
+	assert.False(t, ok)
+}
+
+func TestGitLabProvider(t *testing.T) {
+	t.Setenv("EXAMPLE_GITLAB_TOKEN", "glpat-test")
+	project := "/api/v4/projects/group%2Fsubgroup%2Fproject"
+	useHostServer(t, map[string]string{
+		"gitlab.example.com" + project + "?license=true":                                   `{"description": "A nested project", "web_url": "https://gitlab.example.com/group/subgroup/project", "namespace": {"name": "Subgroup"}, "license": {"key": "mit", "name": "MIT License"}}`,
+		"gitlab.example.com" + project + "/repository/tree?per_page=100&path=module":       `[{"name": "LICENSE", "type": "blob", "path": "module/LICENSE"}, {"name": "pkg", "type": "tree", "path": "module/pkg"}]`,
+		"gitlab.example.com" + project + "/repository/files/module%2FLICENSE/raw?ref=HEAD": "Module license\n",
+	})
+	config := &Config{Hosts: map[string]HostConfig{"gitlab.example.com": {Type: hostGitLab, TokenEnv: "EXAMPLE_GITLAB_TOKEN"}}}
+
+	dep := Dependency{Name: "project/module", FullName: "gitlab.example.com/group/subgroup/project/module", Repository: DependencyRepository{URL: "https://gitlab.example.com/group/subgroup/project"}, DependencyType: GoDep}
+	require.NoError(t, dep.LoadFromHost(context.Background(), config))
+	assert.Equal(t, "A nested project", dep.Description)
+	assert.Equal(t, "Subgroup", dep.Author.Name)
+	assert.Equal(t, "https://gitlab.example.com/group/subgroup/project", dep.HomePage)
+	assert.Equal(t, "MIT License", dep.License)
+	assert.Equal(t, "mit", dep.LicenseID)
+	assert.Equal(t, "Module license\n\n", dep.PopulateLicence(context.Background(), config))
+}
+
+func TestBitbucketProvider(t *testing.T) {
+	repo := "api.bitbucket.org/2.0/repositories/workspace/repo"
+	useHostServer(t, map[string]string{
+		repo:                            `{"description": "A Bitbucket repository", "website": "", "owner": {"display_name": "The Workspace"}, "links": {"html": {"href": "https://bitbucket.org/workspace/repo"}}, "mainbranch": {"name": "main"}}`,
+		repo + "/src/main/?pagelen=100": `{"values": [{"path": "COPYING", "type": "commit_file"}, {"path": "src", "type": "commit_directory"}]}`,
+		repo + "/src/main/COPYING":      "GNU GPL",
+	})
+	config := &Config{}
+
+	dep := Dependency{Name: "workspace/repo", Repository: DependencyRepository{URL: "https://bitbucket.org/workspace/repo.git"}, DependencyType: GoDep}
+	require.NoError(t, dep.LoadFromHost(context.Background(), config))
+	assert.Equal(t, "A Bitbucket repository", dep.Description)
+	assert.Equal(t, "The Workspace", dep.Author.Name)
+	assert.Equal(t, "https://bitbucket.org/workspace/repo", dep.HomePage)
+	assert.Equal(t, "GNU GPL\n\n", dep.PopulateLicence(context.Background(), config))
+}
+
+func TestGiteaProvider(t *testing.T) {
+	repo := "codeberg.org/api/v1/repos/forgejo/forgejo"
+	useHostServer(t, map[string]string{
+		repo:                  `{"description": "Beyond coding", "website": "https://forgejo.org", "html_url": "https://codeberg.org/forgejo/forgejo", "owner": {"login": "forgejo", "full_name": ""}, "licenses": ["GPL-3.0-or-later"]}`,
+		repo + "/contents/":   `[{"name": "LICENSE", "path": "LICENSE", "type": "file"}, {"name": "README.md", "path": "README.md", "type": "file"}]`,
+		repo + "/raw/LICENSE": "GNU GENERAL PUBLIC LICENSE",
+	})
+	config := &Config{}
+
+	dep := Dependency{Name: "forgejo/forgejo", Repository: DependencyRepository{URL: "https://codeberg.org/forgejo/forgejo"}, DependencyType: GoDep}
+	require.NoError(t, dep.LoadFromHost(context.Background(), config))
+	assert.Equal(t, "Beyond coding", dep.Description)
+	assert.Equal(t, "forgejo", dep.Author.Name)
+	assert.Equal(t, "https://forgejo.org", dep.HomePage)
+	assert.Equal(t, "GPL-3.0-or-later", dep.License)
+	assert.Equal(t, "GNU GENERAL PUBLIC LICENSE\n\n", dep.PopulateLicence(context.Background(), config))
+}
+
+func TestSourcehutProvider(t *testing.T) {
+	useHostServer(t, map[string]string{
+		"git.sr.ht/~sircmpwn/getopt/blob/HEAD/LICENSE": "Copyright (c) 2020 Drew DeVault",
+	})
+	config := &Config{}
+
+	dep := Dependency{Name: "~sircmpwn/getopt", Repository: DependencyRepository{URL: "https://git.sr.ht/~sircmpwn/getopt"}, DependencyType: GoDep}
+	require.NoError(t, dep.LoadFromHost(context.Background(), config))
+	assert.Equal(t, "sircmpwn", dep.Author.Name)
+	assert.Equal(t, "https://git.sr.ht/~sircmpwn/getopt", dep.HomePage)
+	assert.Equal(t, "Copyright (c) 2020 Drew DeVault\n\n", dep.PopulateLicence(context.Background(), config))
+}
+
+func TestFindNotice(t *testing.T) {
+	repo := "codeberg.org/api/v1/repos/org/repo"
+	useHostServer(t, map[string]string{
+		repo + "/contents/?ref=v1.0.0":            `[{"name": "NOTICE.txt", "path": "NOTICE.txt", "type": "file"}, {"name": "LICENSE", "path": "LICENSE", "type": "file"}]`,
+		repo + "/raw/NOTICE.txt?ref=v1.0.0":       "Repo 1.0\nCopyright 2020 The Org\n",
+		repo + "/contents/sub?ref=sub%2Fv1.0.0":   `[{"name": "NOTICE", "path": "sub/NOTICE", "type": "file"}]`,
+		repo + "/raw/sub/NOTICE?ref=sub%2Fv1.0.0": "Sub module notice",
+		repo + "/contents/":                       `[{"name": "NOTICE.md", "path": "NOTICE.md", "type": "file"}]`,
+		repo + "/raw/NOTICE.md":                   "Default branch notice",
+	})
+	p := giteaProvider{host: "codeberg.org"}
+	repoURL := "https://codeberg.org/org/repo"
+
+	notice, err := FindNotice(context.Background(), p, repoURL, "v1.0.0", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "Repo 1.0\nCopyright 2020 The Org", notice)
+
+	notice, err = FindNotice(context.Background(), p, repoURL, "sub/v1.0.0", []string{"sub"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Sub module notice", notice)
+
+	// An unknown revision falls back to the default branch
+	notice, err = FindNotice(context.Background(), p, repoURL, "v9.9.9", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "Default branch notice", notice)
+}
+
+func TestGenerateApacheNotice(t *testing.T) {
+	useHostServer(t, map[string]string{
+		"git.sr.ht/~org/apache/blob/HEAD/NOTICE": "Apache Widget\n## Copyright 2020 The Org",
+	})
+	config := &Config{Path: t.TempDir(), Report: &Report{}}
+	require.NoError(t, CreateNoticeDir(config))
+
+	dep := Dependency{Name: "apache-widget", License: "Apache-2.0", LicenseText: "Apache License", Repository: DependencyRepository{URL: "https://git.sr.ht/~org/apache"}, DependencyType: ManualDep}
+	require.NoError(t, dep.Generate(context.Background(), config))
+	assert.Contains(t, dep.Load(config), "Apache License\n\n* NOTICE:\n\n    Apache Widget\n    ## Copyright 2020 The Org\n\n")
+
+	dep = Dependency{Name: "no-notice", License: "Apache License 2.0", LicenseText: "Apache License", Repository: DependencyRepository{URL: "https://git.sr.ht/~org/other"}, DependencyType: ManualDep}
+	require.NoError(t, dep.Generate(context.Background(), config))
+	assert.NotContains(t, dep.Load(config), "NOTICE")
+
+	dep = Dependency{Name: "mit", License: "MIT", LicenseText: "MIT License", Repository: DependencyRepository{URL: "https://git.sr.ht/~org/mit"}, DependencyType: ManualDep}
+	require.NoError(t, dep.Generate(context.Background(), config))
+	assert.Equal(t, []MissingNotice{{Name: "no-notice", Ecosystem: "manual"}}, config.Report.MissingNotices)
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+const userAgent = "mattermost-notice-file-generator"
+
+const (
+	defaultRetries = 4
+	// maxRateLimitWait is the longest we wait for a rate limit reset before
+	// giving up on a request.
+	maxRateLimitWait = 15 * time.Minute
+)
+
+// httpClient is shared by the npm, GitHub and raw content fetches. Tests swap
+// it for a client backed by a fake transport.
+var httpClient = NewHTTPClient(http.DefaultTransport, 30*time.Second, false)
+
+// HTTPError is returned for responses other than 200 OK.
+type HTTPError struct {
+	StatusCode int
+	URL        string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("http status code %d when downloading %q", e.StatusCode, e.URL)
+}
+
+// IsNotFound reports whether err is a 404 response, returned by HTTPGet or
+// the GitHub client.
+func IsNotFound(err error) bool {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusNotFound
+	}
+	var githubErr *github.ErrorResponse
+	return errors.As(err, &githubErr) && githubErr.Response != nil && githubErr.Response.StatusCode == http.StatusNotFound
+}
+
+// RetryTransport retries transient failures with a jittered exponential
+// backoff and waits for rate limits to reset. Every attempt is bounded by
+// Timeout.
+type RetryTransport struct {
+	Base          http.RoundTripper
+	Timeout       time.Duration
+	Retries       int
+	BaseDelay     time.Duration
+	MaxDelay      time.Duration
+	Authenticated bool
+
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewHTTPClient returns a client retrying on top of base. Without a token
+// (authenticated false) rate limits fail fast, as the reset can be an hour away.
+func NewHTTPClient(base http.RoundTripper, timeout time.Duration, authenticated bool) *http.Client {
+	return &http.Client{Transport: &RetryTransport{
+		Base:          base,
+		Timeout:       timeout,
+		Retries:       defaultRetries,
+		BaseDelay:     500 * time.Millisecond,
+		MaxDelay:      30 * time.Second,
+		Authenticated: authenticated,
+	}}
+}
+
+// rateLimitHint names the token raising the rate limit of a host, for the
+// hosts whose token is known.
+func rateLimitHint(host string) string {
+	host = strings.ToLower(host)
+	switch host {
+	case defaultGitHubHost, "api.github.com", "raw.githubusercontent.com":
+		return ", pass a GitHub token with -t, GH_TOKEN or GITHUB_TOKEN to raise the limit"
+	}
+	if env, ok := defaultTokenEnv[strings.TrimPrefix(host, "api.")]; ok {
+		return fmt.Sprintf(", set %s to raise the limit", env)
+	}
+	return ""
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// cancelBody releases the per attempt context once the body is consumed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+func (t *RetryTransport) backoff(attempt int) time.Duration {
+	d := t.BaseDelay << attempt
+	if d > t.MaxDelay || d <= 0 {
+		d = t.MaxDelay
+	}
+	// Full jitter in [d/2, d) spreads the retries of parallel workers
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// rateLimitWait returns how long to wait before retrying a rate limited
+// response, and whether the response was rate limited at all.
+func rateLimitWait(resp *http.Response) (time.Duration, bool) {
+	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+		if date, err := http.ParseTime(retryAfter); err == nil {
+			return time.Until(date), true
+		}
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			return time.Until(time.Unix(reset, 0)), true
+		}
+		return 0, true
+	}
+	return 0, resp.StatusCode == http.StatusTooManyRequests
+}
+
+func isTransientStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	sleep := t.sleep
+	if sleep == nil {
+		sleep = sleepContext
+	}
+
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := req.Context(), context.CancelFunc(func() {})
+		if t.Timeout > 0 {
+			ctx, cancel = context.WithTimeout(req.Context(), t.Timeout)
+		}
+		r := req.Clone(ctx)
+		if r.Header.Get("User-Agent") == "" {
+			r.Header.Set("User-Agent", userAgent)
+		}
+		if req.GetBody != nil && attempt > 0 {
+			body, err := req.GetBody()
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			r.Body = body
+		}
+
+		resp, err := base.RoundTrip(r)
+		if err != nil {
+			cancel()
+			var dnsErr *net.DNSError
+			if req.Context().Err() != nil || attempt >= t.Retries || errors.Is(err, errOffline) || (errors.As(err, &dnsErr) && dnsErr.IsNotFound) {
+				return nil, err
+			}
+			delay := t.backoff(attempt)
+			log.Printf("Request to %s failed (%v), retrying in %s", req.URL, err, delay.Round(time.Millisecond))
+			if err = sleep(req.Context(), delay); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		wait, limited := 0*time.Second, false
+		if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests {
+			wait, limited = rateLimitWait(resp)
+		}
+		if !limited && !isTransientStatus(resp.StatusCode) {
+			resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+			return resp, nil
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		cancel()
+
+		if limited {
+			if !t.Authenticated {
+				return nil, fmt.Errorf("rate limit exceeded for %s (resets in %s)%s", req.URL.Host, wait.Round(time.Second), rateLimitHint(req.URL.Hostname()))
+			}
+			if wait > maxRateLimitWait {
+				return nil, fmt.Errorf("rate limit exceeded for %s, resets in %s", req.URL.Host, wait.Round(time.Second))
+			}
+		}
+		if attempt >= t.Retries {
+			return nil, &HTTPError{StatusCode: resp.StatusCode, URL: req.URL.String()}
+		}
+
+		delay := t.backoff(attempt)
+		if wait > delay {
+			delay = wait
+		}
+		log.Printf("Request to %s returned %d, retrying in %s", req.URL, resp.StatusCode, delay.Round(time.Millisecond))
+		if err = sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func HTTPGet(ctx context.Context, rsc string) (string, error) {
+	return HTTPGetWithHeader(ctx, rsc, nil)
+}
+
+// HTTPGetWithHeader is HTTPGet with additional request headers, ie. the token
+// of a code host API.
+func HTTPGetWithHeader(ctx context.Context, rsc string, header http.Header) (string, error) {
+	out := &bytes.Buffer{}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", rsc, nil)
+	if err != nil {
+		return "", err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return "", &HTTPError{StatusCode: resp.StatusCode, URL: rsc}
+	}
+
+	_, err = io.Copy(out, resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
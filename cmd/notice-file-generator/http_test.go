@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestHTTPClient returns a client that records the backoff delays instead
+// of sleeping.
+func newTestHTTPClient(authenticated bool, delays *[]time.Duration) *http.Client {
+	client := NewHTTPClient(http.DefaultTransport, time.Second, authenticated)
+	client.Transport.(*RetryTransport).sleep = func(ctx context.Context, d time.Duration) error {
+		*delays = append(*delays, d)
+		return nil
+	}
+	return client
+}
+
+func useHTTPClient(t *testing.T, client *http.Client) {
+	previous := httpClient
+	httpClient = client
+	t.Cleanup(func() { httpClient = previous })
+}
+
+func TestHTTPGetRetriesTransientFailures(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		assert.Equal(t, userAgent, r.Header.Get("User-Agent"))
+		if calls < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	var delays []time.Duration
+	useHTTPClient(t, newTestHTTPClient(false, &delays))
+
+	data, err := HTTPGet(context.Background(), server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "content", data)
+	assert.Equal(t, 3, calls)
+	require.Len(t, delays, 2)
+	assert.GreaterOrEqual(t, delays[0], 250*time.Millisecond)
+	assert.GreaterOrEqual(t, delays[1], 500*time.Millisecond)
+}
+
+func TestHTTPGetDoesNotRetryNotFound(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	var delays []time.Duration
+	useHTTPClient(t, newTestHTTPClient(false, &delays))
+
+	_, err := HTTPGet(context.Background(), server.URL)
+	assert.True(t, IsNotFound(err))
+	assert.Equal(t, 1, calls)
+	assert.Empty(t, delays)
+}
+
+func TestHTTPGetGivesUpAfterRetries(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	var delays []time.Duration
+	useHTTPClient(t, newTestHTTPClient(false, &delays))
+
+	_, err := HTTPGet(context.Background(), server.URL)
+	assert.Error(t, err)
+	assert.Len(t, delays, defaultRetries)
+}
+
+func TestHTTPGetRateLimitWithoutToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	var delays []time.Duration
+	useHTTPClient(t, newTestHTTPClient(false, &delays))
+
+	_, err := HTTPGet(context.Background(), server.URL)
+	assert.ErrorContains(t, err, "rate limit exceeded for "+strings.TrimPrefix(server.URL, "http://"))
+	assert.NotContains(t, err.Error(), "token")
+	assert.Empty(t, delays)
+}
+
+func TestRateLimitHint(t *testing.T) {
+	assert.Equal(t, ", pass a GitHub token with -t, GH_TOKEN or GITHUB_TOKEN to raise the limit", rateLimitHint("api.github.com"))
+	assert.Equal(t, ", set GITLAB_TOKEN to raise the limit", rateLimitHint("gitlab.com"))
+	assert.Equal(t, ", set BITBUCKET_TOKEN to raise the limit", rateLimitHint("api.bitbucket.org"))
+	assert.Equal(t, "", rateLimitHint("registry.npmjs.org"))
+	assert.Equal(t, "", rateLimitHint("ghe.example.com"))
+}
+
+func TestHTTPGetWaitsForRateLimitReset(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	var delays []time.Duration
+	useHTTPClient(t, newTestHTTPClient(true, &delays))
+
+	data, err := HTTPGet(context.Background(), server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", data)
+	assert.Equal(t, []time.Duration{120 * time.Second}, delays)
+}
+
+func TestHTTPGetForbiddenIsNotRetried(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	var delays []time.Duration
+	useHTTPClient(t, newTestHTTPClient(true, &delays))
+
+	_, err := HTTPGet(context.Background(), server.URL)
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Display names of Go modules, see Config.DisplayNames.
+const (
+	displayNamesShort = "short"
+	displayNamesFull  = "full"
+)
+
+// purlTypes are the package URL types of the ecosystems. Container packages
+// carry their own, depending on the distribution they come from.
+var purlTypes = map[DependencyType]string{
+	GoDep:        "golang",
+	JsDep:        "npm",
+	ContainerDep: "docker",
+}
+
+// Purl returns the package URL (https://github.com/package-url/purl-spec)
+// of a package, without version: the name segments are escaped, so the scope
+// of an npm package becomes %40scope.
+func Purl(purlType, name string) string {
+	segments := strings.Split(strings.Trim(name, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = strings.ReplaceAll(url.PathEscape(segment), "@", "%40")
+	}
+	return "pkg:" + purlType + "/" + strings.Join(segments, "/")
+}
+
+// Identity is the canonical identity of the dependency: the package URL of
+// its ecosystem and full module path or package name. Unlike the display
+// name, it is unique, so it keys the stanza files, the cache and the grouping
+// of versions. Vendored code is told apart by the folder it lives in.
+func (d *Dependency) Identity() string {
+	if d.Purl != "" {
+		return d.Purl
+	}
+	purlType, ok := purlTypes[d.DependencyType]
+	if !ok {
+		purlType = "generic"
+	}
+	name := d.Name
+	if d.DependencyType == GoDep && d.FullName != "" {
+		name = d.FullName
+	}
+	purl := Purl(purlType, name)
+	if d.DependencyType == VendoredDep && d.Location != "" {
+		purl += "#" + d.Location
+	}
+	return purl
+}
+
+// FileName is the name of the stanza file of the dependency.
+func (d *Dependency) FileName() string {
+	return GenerateFileName(d.Identity())
+}
+
+// legacyFileName is the name the stanza had before stanzas were keyed by
+// identity, which is still the name SplitExistingNotice gives the stanzas of
+// NOTICE.txt as they only carry the display name.
+func (d *Dependency) legacyFileName() string {
+	return GenerateFileName(d.Name)
+}
+
+// existingNotice returns the stanza of a previous run reusable for the
+// dependency, looked up by identity first and then by display name. A
+// dependency known to the lock had its stanza split under its identity, so
+// one found under its display name belongs to another package.
+func (d *Dependency) existingNotice(config *Config) (string, bool) {
+	if HasExistingNotice(config, d.FileName()) {
+		return d.FileName(), true
+	}
+	if _, locked := config.Lock.Entry(d.Identity()); !locked && HasExistingNotice(config, d.legacyFileName()) {
+		return d.legacyFileName(), true
+	}
+	return "", false
+}
+
+// ReuseExistingNotice moves the stanza of a previous run to the work folder,
+// under the identity of the dependency.
+func (d *Dependency) ReuseExistingNotice(config *Config) error {
+	filename, ok := d.existingNotice(config)
+	if !ok {
+		return os.ErrNotExist
+	}
+	return os.Rename(filepath.Join(config.NoticeDirPath(), filename), filepath.Join(config.NoticeWorkPath(), d.FileName()))
+}
+
+// DisambiguateNames gives the full module path as display name to the
+// dependencies whose short name is shared by another package, ie.
+// github.com/a/x and gitlab.com/a/x both shortened to a/x, so their stanzas
+// stay apart in NOTICE.txt.
+func DisambiguateNames(deps []Dependency) {
+	identities := make(map[string]map[string]bool)
+	for _, d := range deps {
+		if identities[d.Name] == nil {
+			identities[d.Name] = make(map[string]bool)
+		}
+		identities[d.Name][d.Identity()] = true
+	}
+	for i := range deps {
+		d := &deps[i]
+		if len(identities[d.Name]) > 1 && d.FullName != "" && d.DependencyType == GoDep {
+			log.Printf("Naming %s after its module path %s, %s is shared by another package", d.Identity(), d.FullName, d.Name)
+			d.Name = d.FullName
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdentity(t *testing.T) {
+	assert.Equal(t, "pkg:golang/github.com/foo/bar/v2", (&Dependency{Name: "bar/v2", FullName: "github.com/foo/bar/v2", DependencyType: GoDep}).Identity())
+	assert.Equal(t, "pkg:npm/%40scope/pkg", (&Dependency{Name: "@scope/pkg", DependencyType: JsDep}).Identity())
+	assert.Equal(t, "pkg:docker/golang", (&Dependency{Name: "golang", FullName: "golang:1.21", DependencyType: ContainerDep}).Identity())
+	assert.Equal(t, "pkg:deb/debian/libc6", (&Dependency{Name: "libc6", Purl: Purl("deb", "debian/libc6"), DependencyType: ContainerDep}).Identity())
+	assert.Equal(t, "pkg:generic/zlib#third_party/zlib", (&Dependency{Name: "zlib", Location: "third_party/zlib", DependencyType: VendoredDep}).Identity())
+	assert.Equal(t, "pkg:generic/Inter%20font", (&Dependency{Name: "Inter font", DependencyType: ManualDep}).Identity())
+
+	// Packages sharing a short name get their own stanza file
+	github := Dependency{Name: "a/x", FullName: "github.com/a/x", DependencyType: GoDep}
+	gitlab := Dependency{Name: "a/x", FullName: "gitlab.com/a/x", DependencyType: GoDep}
+	npm := Dependency{Name: "a/x", DependencyType: JsDep}
+	assert.NotEqual(t, github.FileName(), gitlab.FileName())
+	assert.NotEqual(t, github.FileName(), npm.FileName())
+}
+
+func TestDisambiguateNames(t *testing.T) {
+	deps := []Dependency{
+		{Name: "a/x", FullName: "github.com/a/x", DependencyType: GoDep},
+		{Name: "a/x", FullName: "gitlab.com/a/x", DependencyType: GoDep},
+		{Name: "b/y", FullName: "github.com/b/y", DependencyType: GoDep},
+		{Name: "react", DependencyType: JsDep},
+	}
+	DisambiguateNames(deps)
+	assert.Equal(t, "github.com/a/x", deps[0].Name)
+	assert.Equal(t, "gitlab.com/a/x", deps[1].Name)
+	assert.Equal(t, "b/y", deps[2].Name)
+	assert.Equal(t, "react", deps[3].Name)
+}
+
+func TestReuseExistingNotice(t *testing.T) {
+	config := &Config{Path: t.TempDir()}
+	require.NoError(t, os.MkdirAll(config.NoticeDirPath(), os.ModePerm))
+	require.NoError(t, CreateNoticeDir(config))
+
+	// Stanzas split from NOTICE.txt are only known by their display name
+	legacy := Dependency{Name: "foo/bar", FullName: "github.com/foo/bar", DependencyType: GoDep}
+	writeTestFile(t, filepath.Join(config.NoticeDirPath(), "foo-bar"), "## foo/bar\n")
+	require.NoError(t, legacy.ReuseExistingNotice(config))
+	assert.Equal(t, "## foo/bar\n", legacy.Load(config))
+
+	keyed := Dependency{Name: "x", FullName: "github.com/a/x", DependencyType: GoDep}
+	writeTestFile(t, filepath.Join(config.NoticeDirPath(), "x"), "## x (legacy)\n")
+	writeTestFile(t, filepath.Join(config.NoticeDirPath(), keyed.FileName()), "## x\n")
+	require.NoError(t, keyed.ReuseExistingNotice(config))
+	assert.Equal(t, "## x\n", keyed.Load(config))
+
+	missing := Dependency{Name: "missing", DependencyType: JsDep}
+	assert.Error(t, missing.ReuseExistingNotice(config))
+}
+
+func TestIgnoreRuleIdentity(t *testing.T) {
+	rule := IgnoreRule{Pattern: "pkg:golang/github.com/mattermost/**"}
+	assert.True(t, rule.Matches(Dependency{Name: "mattermost/server", FullName: "github.com/mattermost/mattermost/server/v8", DependencyType: GoDep}))
+	assert.False(t, rule.Matches(Dependency{Name: "github.com/mattermost/types", DependencyType: JsDep}))
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// IgnoreRule drops dependencies from the notice. A plain string in the
+// configuration is a glob pattern, where * matches within a path segment and
+// ** across segments. Patterns and regular expressions are matched against
+// the name, the full module path and the identity of a dependency, ie.
+// pkg:golang/github.com/mattermost/**.
+type IgnoreRule struct {
+	Pattern   string `yaml:"pattern"`
+	Regex     string `yaml:"regex"`
+	Ecosystem string `yaml:"ecosystem"`
+	Reason    string `yaml:"reason"`
+
+	matchers []*regexp.Regexp
+}
+
+func (r *IgnoreRule) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		r.Pattern = value.Value
+	} else {
+		type plain IgnoreRule
+		if err := value.Decode((*plain)(r)); err != nil {
+			return err
+		}
+	}
+	return r.compile()
+}
+
+func (r *IgnoreRule) compile() error {
+	r.matchers = nil
+	if r.Pattern != "" {
+		r.matchers = append(r.matchers, globToRegexp(r.Pattern))
+	}
+	if r.Regex != "" {
+		re, err := regexp.Compile(r.Regex)
+		if err != nil {
+			return fmt.Errorf("invalid ignoreDependencies regex %q: %w", r.Regex, err)
+		}
+		r.matchers = append(r.matchers, re)
+	}
+	if len(r.matchers) == 0 && r.Ecosystem == "" {
+		return fmt.Errorf("ignoreDependencies entries need a pattern, a regex or an ecosystem")
+	}
+	return nil
+}
+
+func globToRegexp(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// String describes the rule for logs and reports.
+func (r IgnoreRule) String() string {
+	var parts []string
+	if r.Ecosystem != "" {
+		parts = append(parts, "ecosystem "+r.Ecosystem)
+	}
+	if r.Pattern != "" {
+		parts = append(parts, "pattern "+r.Pattern)
+	}
+	if r.Regex != "" {
+		parts = append(parts, "regex "+r.Regex)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Matches reports whether the dependency is ignored by the rule. When both a
+// pattern and a regex are set, either of them has to match.
+func (r *IgnoreRule) Matches(d Dependency) bool {
+	if r.matchers == nil && (r.Pattern != "" || r.Regex != "") {
+		if err := r.compile(); err != nil {
+			return false
+		}
+	}
+	if r.Ecosystem != "" && !strings.EqualFold(r.Ecosystem, d.DependencyType.String()) {
+		return false
+	}
+	if len(r.matchers) == 0 {
+		return true
+	}
+	for _, m := range r.matchers {
+		if m.MatchString(d.Name) || (d.FullName != "" && m.MatchString(d.FullName)) || m.MatchString(d.Identity()) {
+			return true
+		}
+	}
+	return false
+}
+
+func RemoveIgnoredDependencies(allDeps []Dependency, depsToIgnore []IgnoreRule) ([]Dependency, []IgnoredDependency) {
+	var filteredDeps []Dependency
+	var ignoredDeps []IgnoredDependency
+	for _, dep := range allDeps {
+		ignored := false
+		for i := range depsToIgnore {
+			rule := &depsToIgnore[i]
+			if rule.Matches(dep) {
+				if rule.Reason != "" {
+					log.Printf("Ignoring %s dependency %s (%s): %s", dep.DependencyType, dep.Name, rule, rule.Reason)
+				} else {
+					log.Printf("Ignoring %s dependency %s (%s)", dep.DependencyType, dep.Name, rule)
+				}
+				ignoredDeps = append(ignoredDeps, IgnoredDependency{
+					Name:      dep.Name,
+					Ecosystem: dep.DependencyType.String(),
+					Rule:      rule.String(),
+					Reason:    rule.Reason,
+				})
+				ignored = true
+				break
+			}
+		}
+		if !ignored {
+			filteredDeps = append(filteredDeps, dep)
+		}
+	}
+	return filteredDeps, ignoredDeps
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestIgnoreRules(t *testing.T) {
+	var config Config
+	err := yaml.Unmarshal([]byte(`
+ignoreDependencies:
+  - wix
+  - "@mattermost/*"
+  - pattern: "github.com/mattermost/**"
+    reason: "first party code"
+  - regex: "^golang\\.org/x/"
+  - ecosystem: container
+    pattern: "lib*"
+  - ecosystem: vendored
+`), &config)
+	assert.NoError(t, err)
+	assert.Equal(t, "first party code", config.IgnoreDependencies[2].Reason)
+
+	deps := []Dependency{
+		{Name: "wix"},
+		{Name: "wixtoolset"},
+		{Name: "@mattermost/types", DependencyType: JsDep},
+		{Name: "@mattermost/types/nested", DependencyType: JsDep},
+		{Name: "mattermost/server", FullName: "github.com/mattermost/mattermost/server/v8", DependencyType: GoDep},
+		{Name: "golang/mod", FullName: "golang.org/x/mod", DependencyType: GoDep},
+		{Name: "libc6", DependencyType: ContainerDep},
+		{Name: "libfoo", DependencyType: JsDep},
+		{Name: "zlib", DependencyType: VendoredDep},
+	}
+
+	var names []string
+	filtered, ignored := RemoveIgnoredDependencies(deps, config.IgnoreDependencies)
+	for _, d := range filtered {
+		names = append(names, d.Name)
+	}
+	assert.Equal(t, []string{"wixtoolset", "@mattermost/types/nested", "libfoo"}, names)
+	assert.Len(t, ignored, 6)
+	assert.Equal(t, IgnoredDependency{Name: "mattermost/server", Ecosystem: "go", Rule: "pattern github.com/mattermost/**", Reason: "first party code"}, ignored[2])
+}
+
+func TestIgnoreRuleInvalid(t *testing.T) {
+	var config Config
+	assert.Error(t, yaml.Unmarshal([]byte("ignoreDependencies:\n  - regex: \"(\"\n"), &config))
+	assert.Error(t, yaml.Unmarshal([]byte("ignoreDependencies:\n  - reason: \"no matcher\"\n"), &config))
+}
+
+func TestIgnoreRuleWithoutConfigFile(t *testing.T) {
+	rule := IgnoreRule{Pattern: "react-*"}
+	assert.True(t, rule.Matches(Dependency{Name: "react-dom"}))
+	assert.False(t, rule.Matches(Dependency{Name: "react"}))
+}
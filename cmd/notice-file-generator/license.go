@@ -0,0 +1,232 @@
+package main
+
+import (
+	"embed"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// regexpLicenseFile matches the usual names of license files, ie. LICENSE,
+// LICENSE-MIT, licence.txt or COPYING.
+var regexpLicenseFile = regexp.MustCompile(`(?i)^(licen[cs]e|copying|unlicense|ofl)([-.][A-Za-z0-9.-]+)?$`)
+
+// regexpNoticeFile matches the NOTICE file required by section 4(d) of the
+// Apache License 2.0.
+var regexpNoticeFile = regexp.MustCompile(`(?i)^notice(\.txt|\.md)?$`)
+
+var regexpWhitespace = regexp.MustCompile(`\s+`)
+
+type licenseMatcher struct {
+	id      string
+	phrases []string
+}
+
+// matches reports whether a text normalised for matching contains all the
+// phrases of the license.
+func (m licenseMatcher) matches(normalised string) bool {
+	for _, phrase := range m.phrases {
+		if !strings.Contains(normalised, phrase) {
+			return false
+		}
+	}
+	return true
+}
+
+// licenseMatchers are checked in order, more specific licenses first, and a
+// license matches when its text contains all the phrases. The Mozilla and
+// Eclipse licenses name the GNU licenses they are compatible with, and the GPL
+// names the Affero GPL, so they are told apart by their own phrases.
+var licenseMatchers = []licenseMatcher{
+	{"MPL-2.0", []string{"mozilla public license", "2.0"}},
+	{"EPL-2.0", []string{"eclipse public license", "v 2.0"}},
+	{"AGPL-3.0", []string{"gnu affero general public license", "version 3, 19 november 2007"}},
+	// The GPL suggests the LGPL for libraries, its preamble tells them apart
+	{"GPL-3.0", []string{"the gnu general public license is a free, copyleft license"}},
+	{"LGPL-3.0", []string{"gnu lesser general public license", "version 3"}},
+	{"LGPL-2.1", []string{"gnu lesser general public license", "version 2.1"}},
+	{"LGPL-2.0", []string{"gnu library general public license", "version 2"}},
+	{"GPL-3.0", []string{"gnu general public license", "version 3"}},
+	{"GPL-2.0", []string{"gnu general public license", "version 2"}},
+	{"Apache-2.0", []string{"apache license", "version 2.0"}},
+	{"BSL-1.0", []string{"boost software license"}},
+	{"OFL-1.1", []string{"sil open font license"}},
+	{"CC0-1.0", []string{"cc0 1.0 universal"}},
+	{"Unlicense", []string{"this is free and unencumbered software released into the public domain"}},
+	{"Zlib", []string{"provided 'as-is', without any express or implied warranty", "altered source versions must be plainly marked"}},
+	{"0BSD", []string{"with or without fee is hereby granted. the software is provided"}},
+	{"ISC", []string{"permission to use, copy, modify, and", "distribute this software for any purpose with or without fee is hereby granted"}},
+	{"MIT-0", []string{"furnished to do so. the software is provided"}},
+	{"MIT", []string{"permission is hereby granted, free of charge, to any person obtaining a copy"}},
+	{"BSD-3-Clause", []string{"redistribution and use in source and binary forms", "neither the name"}},
+	{"BSD-2-Clause", []string{"redistribution and use in source and binary forms"}},
+}
+
+// IsLicenseFile reports whether the file name looks like a license file.
+func IsLicenseFile(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".go", ".js", ".ts", ".py", ".json", ".html":
+		return false
+	}
+	return regexpLicenseFile.MatchString(name)
+}
+
+// IsNoticeFile reports whether the file name is a NOTICE file.
+func IsNoticeFile(name string) bool {
+	return regexpNoticeFile.MatchString(name)
+}
+
+// IsApacheLicensed reports whether the dependency is under the Apache License
+// 2.0, which requires its NOTICE file to be redistributed.
+func (d *Dependency) IsApacheLicensed() bool {
+	licenses := []string{d.LicenseID, d.License}
+	if d.ElectedLicense != nil {
+		licenses = d.ApplicableLicenses()
+	}
+	for _, license := range licenses {
+		if CanonicalLicenseID(license) == "Apache-2.0" {
+			return true
+		}
+	}
+	return d.License == "" && IdentifyLicense(d.LicenseText) == "Apache-2.0"
+}
+
+// IdentifyLicense returns the SPDX identifier of a license text, or an empty
+// string when the license is not recognised.
+func IdentifyLicense(text string) string {
+	normalised := normaliseForMatching(text)
+
+	for _, m := range licenseMatchers {
+		if m.matches(normalised) {
+			return m.id
+		}
+	}
+	return ""
+}
+
+// normaliseForMatching lower cases a license text and collapses its
+// whitespace and quotes, so the phrases of licenseMatchers can be found.
+func normaliseForMatching(text string) string {
+	normalised := regexpWhitespace.ReplaceAllString(strings.ToLower(text), " ")
+	return strings.NewReplacer("‘", "'", "’", "'", "`", "'").Replace(normalised)
+}
+
+// LicenseTextMissing reports whether a recognised license is missing from a
+// text. The texts of unknown licenses cannot be checked and are assumed present.
+func LicenseTextMissing(text, license string) bool {
+	id := CanonicalLicenseID(license)
+	normalised := normaliseForMatching(text)
+	known := false
+	for _, m := range licenseMatchers {
+		if !strings.EqualFold(m.id, id) {
+			continue
+		}
+		known = true
+		if m.matches(normalised) {
+			return false
+		}
+	}
+	return known
+}
+
+// The texts of licenses are the hand-wrapped ones of licenses, and those of
+// licenses/spdx generated from the SPDX license list by gen_licenses.go.
+//
+//go:generate go run gen_licenses.go
+//go:embed licenses/*.txt licenses/spdx/*.txt
+var licenseCorpus embed.FS
+
+// licenseAliases maps the license names used by GitHub and package registries
+// to their SPDX identifier.
+var licenseAliases = map[string]string{
+	"mit license":                         "MIT",
+	"the mit license":                     "MIT",
+	"apache license 2.0":                  "Apache-2.0",
+	"apache 2.0":                          "Apache-2.0",
+	"apache-2":                            "Apache-2.0",
+	"bsd 2-clause \"simplified\" license": "BSD-2-Clause",
+	"bsd 3-clause \"new\" or \"revised\" license": "BSD-3-Clause",
+	"isc license":                            "ISC",
+	"mozilla public license 2.0":             "MPL-2.0",
+	"gnu general public license v2.0":        "GPL-2.0",
+	"gnu general public license v3.0":        "GPL-3.0",
+	"gnu lesser general public license v2.1": "LGPL-2.1",
+	"gnu lesser general public license v3.0": "LGPL-3.0",
+	"the unlicense":                          "Unlicense",
+	"zlib license":                           "Zlib",
+	"creative commons zero v1.0 universal":   "CC0-1.0",
+}
+
+// CanonicalLicenseID returns the SPDX identifier for a license name, without
+// the -only and -or-later variants which share the same text.
+func CanonicalLicenseID(license string) string {
+	id := strings.TrimSpace(license)
+	if alias, ok := licenseAliases[strings.ToLower(id)]; ok {
+		return alias
+	}
+	id = strings.TrimSuffix(id, "+")
+	id = strings.TrimSuffix(id, "-only")
+	id = strings.TrimSuffix(id, "-or-later")
+	return id
+}
+
+var (
+	licenseCorpusOnce  sync.Once
+	licenseCorpusFiles map[string]string
+)
+
+// StandardLicenseText returns the embedded standard text of a license, with
+// its placeholders left as is.
+func StandardLicenseText(license string) (string, bool) {
+	licenseCorpusOnce.Do(func() {
+		licenseCorpusFiles = map[string]string{}
+		// The hand-wrapped texts take precedence over the generated ones
+		for _, dir := range []string{"licenses/spdx", "licenses"} {
+			entries, _ := licenseCorpus.ReadDir(dir)
+			for _, e := range entries {
+				if !e.IsDir() {
+					licenseCorpusFiles[strings.ToLower(strings.TrimSuffix(e.Name(), ".txt"))] = dir + "/" + e.Name()
+				}
+			}
+		}
+	})
+	name, ok := licenseCorpusFiles[strings.ToLower(CanonicalLicenseID(license))]
+	if !ok {
+		return "", false
+	}
+	data, err := licenseCorpus.ReadFile(name)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// standardCopyrightPlaceholder is the copyright line of the standard texts
+// which name the holder in the license itself.
+const standardCopyrightPlaceholder = "Copyright (c) <year> <copyright holders>"
+
+// RenderStandardLicense returns the standard text of a license for a
+// dependency whose license file could not be found. The copyright line is
+// filled in with the statements found in the sources, and added on top of the
+// licenses without such a line. Without statements the placeholder is kept, as
+// the package author is not necessarily the copyright holder.
+func RenderStandardLicense(license string, copyrights []Copyright) (string, bool) {
+	text, ok := StandardLicenseText(license)
+	if !ok {
+		return "", false
+	}
+	text = strings.TrimSpace(text)
+	var statements []string
+	for _, c := range copyrights {
+		statements = append(statements, c.String())
+	}
+	switch {
+	case len(statements) == 0:
+	case strings.Contains(text, standardCopyrightPlaceholder):
+		text = strings.Replace(text, standardCopyrightPlaceholder, strings.Join(statements, "\n"), 1)
+	default:
+		text = strings.Join(statements, "\n") + "\n\n" + text
+	}
+	return text, true
+}
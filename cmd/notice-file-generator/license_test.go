@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsLicenseFile(t *testing.T) {
+	for _, name := range []string{"LICENSE", "LICENSE.txt", "license.md", "LICENSE-MIT", "LICENCE", "COPYING", "COPYING.LESSER", "OFL.txt", "UNLICENSE"} {
+		assert.True(t, IsLicenseFile(name), name)
+	}
+	for _, name := range []string{"README.md", "license_test.go", "licenses", "license.go", "main.go"} {
+		assert.False(t, IsLicenseFile(name), name)
+	}
+}
+
+func TestIdentifyLicense(t *testing.T) {
+	assert.Equal(t, "MIT", IdentifyLicense(`Copyright (c) 2020 Jane Doe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software")`))
+	assert.Equal(t, "Apache-2.0", IdentifyLicense("                                 Apache License\n                           Version 2.0, January 2004"))
+	assert.Equal(t, "BSD-3-Clause", IdentifyLicense("Redistribution and use in source and binary forms, with or without\nmodification, are permitted...\n   * Neither the name of Google Inc."))
+	assert.Equal(t, "BSD-2-Clause", IdentifyLicense("Redistribution and use in source and binary forms, with or without modification"))
+	assert.Equal(t, "LGPL-2.1", IdentifyLicense("GNU LESSER GENERAL PUBLIC LICENSE\n Version 2.1, February 1999"))
+	assert.Equal(t, "GPL-3.0", IdentifyLicense("GNU GENERAL PUBLIC LICENSE\n Version 3, 29 June 2007"))
+	assert.Equal(t, "OFL-1.1", IdentifyLicense("This Font Software is licensed under the SIL Open Font License, Version 1.1."))
+	assert.Equal(t, "", IdentifyLicense("All rights reserved."))
+}
+
+func TestStandardLicenseText(t *testing.T) {
+	text, ok := StandardLicenseText("MIT")
+	assert.True(t, ok)
+	assert.Contains(t, text, "Permission is hereby granted")
+
+	text, ok = StandardLicenseText(`BSD 3-Clause "New" or "Revised" License`)
+	assert.True(t, ok)
+	assert.Contains(t, text, "Neither the name of the copyright holder")
+
+	_, ok = StandardLicenseText("GPL-2.0-or-later")
+	assert.True(t, ok)
+	_, ok = StandardLicenseText("apache-2.0")
+	assert.True(t, ok)
+	_, ok = StandardLicenseText("NOASSERTION")
+	assert.False(t, ok)
+	_, ok = StandardLicenseText("../MIT")
+	assert.False(t, ok)
+
+	// The rest of the SPDX license list is generated
+	text, ok = StandardLicenseText("MPL-1.1")
+	assert.True(t, ok)
+	assert.True(t, strings.HasPrefix(text, "Mozilla Public License Version 1.1\n"))
+	_, ok = StandardLicenseText("cc-by-4.0")
+	assert.True(t, ok)
+	_, ok = StandardLicenseText("GPL-1.0-or-later")
+	assert.True(t, ok)
+	text, ok = StandardLicenseText("BSD-4-Clause")
+	assert.True(t, ok)
+	assert.True(t, strings.HasPrefix(text, standardCopyrightPlaceholder+"\n"))
+
+	// Every license IdentifyLicense recognises has its standard text
+	for _, m := range licenseMatchers {
+		text, ok = StandardLicenseText(m.id)
+		assert.True(t, ok, m.id)
+		assert.Equal(t, m.id, IdentifyLicense(text))
+	}
+}
+
+func TestRenderStandardLicense(t *testing.T) {
+	text, ok := RenderStandardLicense("MIT", []Copyright{{Years: "2019", Holder: "Jane Doe"}, {Holder: "Acme Corp"}})
+	assert.True(t, ok)
+	assert.True(t, strings.HasPrefix(text, "MIT License\n\nCopyright (c) 2019 Jane Doe\nCopyright (c) Acme Corp\n\nPermission is hereby granted"))
+
+	text, ok = RenderStandardLicense("ISC", []Copyright{{Holder: "Jane Doe"}})
+	assert.True(t, ok)
+	assert.Contains(t, text, "\nCopyright (c) Jane Doe\n")
+
+	// The Apache License has no copyright line of its own
+	text, ok = RenderStandardLicense("Apache-2.0", []Copyright{{Years: "2020", Holder: "The Org"}})
+	assert.True(t, ok)
+	assert.True(t, strings.HasPrefix(text, "Copyright (c) 2020 The Org\n\n"))
+	assert.Contains(t, text, "Copyright [yyyy] [name of copyright owner]")
+
+	// No copyright is made up without statements
+	text, ok = RenderStandardLicense("BSD-2-Clause", nil)
+	assert.True(t, ok)
+	assert.Contains(t, text, standardCopyrightPlaceholder)
+	text, ok = RenderStandardLicense("Apache-2.0", nil)
+	assert.True(t, ok)
+	assert.True(t, strings.HasPrefix(text, "Apache License"))
+
+	_, ok = RenderStandardLicense("LicenseRef-Custom", nil)
+	assert.False(t, ok)
+}
+
+func TestGenerateStandardLicenseFallback(t *testing.T) {
+	config := &Config{Path: t.TempDir(), Report: &Report{}}
+	require.NoError(t, CreateNoticeDir(config))
+
+	dep := Dependency{Name: "widget", Author: DependencyAuthor{Name: "Jane Doe"}, License: "MIT", DependencyType: ManualDep}
+	require.NoError(t, dep.Generate(context.Background(), config))
+	stanza, err := os.ReadFile(filepath.Join(config.NoticeWorkPath(), dep.FileName()))
+	require.NoError(t, err)
+	assert.Contains(t, string(stanza), "* LICENSE: MIT\n\n* LICENSE TEXT: standard MIT text, not verified against upstream\n\nMIT License\n\n"+standardCopyrightPlaceholder+"\n\nPermission is hereby granted")
+
+	dep = Dependency{Name: "unknown", License: "LicenseRef-Custom", DependencyType: ManualDep}
+	require.NoError(t, dep.Generate(context.Background(), config))
+	stanza, err = os.ReadFile(filepath.Join(config.NoticeWorkPath(), dep.FileName()))
+	require.NoError(t, err)
+	assert.NotContains(t, string(stanza), "standard")
+
+	// A license without any text is a warning, the stanza being written
+	assert.False(t, config.Report.HasFailures())
+	assert.Equal(t, []MissingLicenseText{{Name: "unknown", Ecosystem: "manual", License: "LicenseRef-Custom"}}, config.Report.MissingLicenseTexts)
+}
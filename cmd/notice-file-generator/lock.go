@@ -0,0 +1,231 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// lockFileName is the file of the notice folder recording how every stanza of
+// NOTICE.txt was produced.
+const lockFileName = "notice.lock.json"
+
+const lockFileVersion = 1
+
+// LockEntry describes the stanza of a dependency: what it was generated from,
+// when, and whether it was written or edited by hand.
+type LockEntry struct {
+	Identity    string   `json:"identity"`
+	Name        string   `json:"name"`
+	Ecosystem   string   `json:"ecosystem"`
+	Version     string   `json:"version,omitempty"`
+	Versions    []string `json:"versions,omitempty"`
+	LicenseID   string   `json:"licenseId,omitempty"`
+	HomePage    string   `json:"homepage,omitempty"`
+	Repository  string   `json:"repository,omitempty"`
+	LicenseURL  string   `json:"licenseURL,omitempty"`
+	LicenseHash string   `json:"licenseHash,omitempty"`
+	NoticeHash  string   `json:"noticeHash,omitempty"`
+	// OverrideHash is the hash of the configured override the stanza was
+	// generated with, if any
+	OverrideHash string `json:"overrideHash,omitempty"`
+	// MetadataHash is the hash of the configured metadata of an additional
+	// dependency the stanza was generated from
+	MetadataHash string    `json:"metadataHash,omitempty"`
+	StanzaHash   string    `json:"stanzaHash"`
+	GeneratedAt  time.Time `json:"generatedAt"`
+	// Manual stanzas are kept as they are: those of dependencies the tool
+	// cannot generate and those edited in NOTICE.txt since they were written
+	Manual bool `json:"manual,omitempty"`
+}
+
+// Lock is the content of the lock file. The entries of the previous run are
+// read before NOTICE.txt is split, the stanzas generated during the run are
+// recorded as they are written. It is safe for concurrent use and a nil lock
+// discards everything.
+type Lock struct {
+	mu              sync.Mutex
+	LockfileVersion int         `json:"lockfileVersion"`
+	Dependencies    []LockEntry `json:"dependencies"`
+
+	recorded map[string]LockEntry
+}
+
+func (c *Config) LockFilePath() string {
+	return filepath.Join(c.NoticeDirPath(), lockFileName)
+}
+
+// ReadLock reads a lock file, a missing file being an empty lock.
+func ReadLock(name string) (*Lock, error) {
+	lock := &Lock{LockfileVersion: lockFileVersion}
+	data, err := os.ReadFile(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return lock, nil
+	}
+	if err != nil {
+		return lock, err
+	}
+	if err = json.Unmarshal(data, lock); err != nil {
+		return &Lock{LockfileVersion: lockFileVersion}, fmt.Errorf("invalid lock file %s: %w", name, err)
+	}
+	if lock.LockfileVersion > lockFileVersion {
+		return &Lock{LockfileVersion: lockFileVersion}, fmt.Errorf("lock file %s has version %d, this tool only reads version %d", name, lock.LockfileVersion, lockFileVersion)
+	}
+	return lock, nil
+}
+
+// Entry returns the entry of the previous run for a dependency identity.
+func (l *Lock) Entry(identity string) (LockEntry, bool) {
+	if l == nil {
+		return LockEntry{}, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, e := range l.Dependencies {
+		if e.Identity == identity {
+			return e, true
+		}
+	}
+	return LockEntry{}, false
+}
+
+// entryByName returns the entry of the previous run whose stanza has the
+// given title, unless several stanzas share it.
+func (l *Lock) entryByName(name string) (LockEntry, bool) {
+	if l == nil {
+		return LockEntry{}, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var found []LockEntry
+	for _, e := range l.Dependencies {
+		if e.Name == name {
+			found = append(found, e)
+		}
+	}
+	if len(found) != 1 {
+		return LockEntry{}, false
+	}
+	return found[0], true
+}
+
+// Record stores the entry of a stanza generated during the run.
+func (l *Lock) Record(entry LockEntry) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.recorded == nil {
+		l.recorded = make(map[string]LockEntry)
+	}
+	l.recorded[entry.Identity] = entry
+}
+
+// markEdited flags as manual the stanzas of the previous run whose text in
+// the notice folder no longer matches the lock, ie. edited in NOTICE.txt.
+func (l *Lock) markEdited(dir string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, e := range l.Dependencies {
+		if e.Manual || e.StanzaHash == "" {
+			continue
+		}
+		stanza, err := os.ReadFile(filepath.Join(dir, GenerateFileName(e.Identity)))
+		if err != nil {
+			continue
+		}
+		if textHash(string(stanza)) != e.StanzaHash {
+			log.Printf("The stanza of %s was edited in NOTICE.txt, it is kept as is", e.Name)
+			l.Dependencies[i].Manual = true
+		}
+	}
+}
+
+// textHash is the SHA-256 of a text without its surrounding whitespace.
+func textHash(text string) string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(text))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// lockEntry describes the stanza generated for the dependency.
+func (d *Dependency) lockEntry() LockEntry {
+	licenseID := d.LicenseID
+	if d.ElectedLicense != nil {
+		licenseID = d.ElectedLicense.String()
+	} else if licenseID == "" || licenseID == "NOASSERTION" {
+		licenseID = d.License
+	}
+	return LockEntry{
+		Identity:     d.Identity(),
+		Name:         d.Name,
+		Ecosystem:    d.DependencyType.String(),
+		Version:      d.Version,
+		Versions:     d.Versions,
+		LicenseID:    licenseID,
+		HomePage:     d.HomePage,
+		Repository:   d.Repository.URL,
+		LicenseURL:   d.LicenseURL,
+		LicenseHash:  textHash(d.LicenseText),
+		NoticeHash:   textHash(d.NoticeText),
+		MetadataHash: d.MetadataHash,
+		GeneratedAt:  time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+// WriteLock stores the lock file of the stanzas of NOTICE.txt. The entries of
+// reused stanzas are carried over unchanged from the previous run, as they
+// describe the versions the stanza shows, those of stanzas found in NOTICE.txt
+// but unknown to the lock are added as of now, and dependencies that are gone
+// are dropped.
+func WriteLock(config *Config, dependencies []Dependency) error {
+	l := config.Lock
+	if l == nil {
+		return nil
+	}
+	lock := Lock{LockfileVersion: lockFileVersion, Dependencies: []LockEntry{}}
+	for _, d := range dependencies {
+		stanza := d.Load(config)
+		if stanza == "" {
+			continue
+		}
+		l.mu.Lock()
+		entry, ok := l.recorded[d.Identity()]
+		l.mu.Unlock()
+		if !ok {
+			if entry, ok = l.Entry(d.Identity()); !ok {
+				entry = d.lockEntry()
+				// Dependencies without type are plain additional
+				// dependencies, whose stanza is written by hand
+				entry.Manual = d.DependencyType == 0
+			}
+		}
+		entry.StanzaHash = textHash(stanza)
+		lock.Dependencies = append(lock.Dependencies, entry)
+	}
+	sort.Slice(lock.Dependencies, func(i, j int) bool {
+		return lock.Dependencies[i].Identity < lock.Dependencies[j].Identity
+	})
+
+	data, err := json.MarshalIndent(&lock, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(config.LockFilePath(), append(data, '\n'))
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// generateNotice runs the steps of main on deps: split NOTICE.txt, generate
+// the stanzas, write NOTICE.txt and the lock file.
+func generateNotice(t *testing.T, config *Config, deps []Dependency) {
+	var err error
+	config.Lock, err = ReadLock(config.LockFilePath())
+	require.NoError(t, err)
+	require.NoError(t, SplitExistingNotice(config))
+	require.NoError(t, os.RemoveAll(config.NoticeWorkPath()))
+	require.NoError(t, CreateNoticeDir(config))
+	for _, d := range deps {
+		require.NoError(t, d.Generate(context.Background(), config))
+	}
+	require.NoError(t, UpdateNotice(config, deps))
+	require.NoError(t, WriteLock(config, deps))
+}
+
+func TestLockFile(t *testing.T) {
+	config := &Config{Path: t.TempDir(), Title: "Title", Report: &Report{}}
+	require.NoError(t, os.MkdirAll(config.NoticeDirPath(), os.ModePerm))
+	writeTestFile(t, config.NoticeFilePath(), "Title\n\n--------\n\n## wix\n\nWritten by hand.\n\n")
+	deps := []Dependency{
+		{Name: "widget", Version: "1.0.0", License: "MIT", LicenseText: mitLicense, HomePage: "https://example.com/widget", DependencyType: ManualDep},
+		{Name: "wix"},
+	}
+	generateNotice(t, config, deps)
+
+	lock, err := ReadLock(config.LockFilePath())
+	require.NoError(t, err)
+	require.Len(t, lock.Dependencies, 2)
+	widget := lock.Dependencies[0]
+	assert.Equal(t, "pkg:generic/widget", widget.Identity)
+	assert.Equal(t, "widget", widget.Name)
+	assert.Equal(t, "manual", widget.Ecosystem)
+	assert.Equal(t, "1.0.0", widget.Version)
+	assert.Equal(t, "MIT", widget.LicenseID)
+	assert.Equal(t, "https://example.com/widget", widget.HomePage)
+	assert.Equal(t, textHash(mitLicense), widget.LicenseHash)
+	assert.Equal(t, textHash(deps[0].Load(config)), widget.StanzaHash)
+	assert.False(t, widget.GeneratedAt.IsZero())
+	assert.False(t, widget.Manual)
+	assert.True(t, lock.Dependencies[1].Manual)
+
+	// A reused stanza keeps the entry of its generation
+	deps[0].Version = "2.0.0"
+	deps[0].Versions = []string{"1.0.0", "2.0.0"}
+	generateNotice(t, config, deps)
+	lock, err = ReadLock(config.LockFilePath())
+	require.NoError(t, err)
+	assert.Equal(t, widget, lock.Dependencies[0])
+}
+
+func TestLockFileEditedStanza(t *testing.T) {
+	config := &Config{Path: t.TempDir(), Title: "Title", Report: &Report{}}
+	deps := []Dependency{{Name: "widget", License: "MIT", LicenseText: mitLicense, DependencyType: ManualDep}}
+	generateNotice(t, config, deps)
+
+	notice, err := os.ReadFile(config.NoticeFilePath())
+	require.NoError(t, err)
+	edited := strings.Replace(string(notice), "This product contains 'widget'", "This product contains 'widget', patched by us", 1)
+	writeTestFile(t, config.NoticeFilePath(), edited)
+
+	generateNotice(t, config, deps)
+	lock, err := ReadLock(config.LockFilePath())
+	require.NoError(t, err)
+	require.Len(t, lock.Dependencies, 1)
+	assert.True(t, lock.Dependencies[0].Manual)
+	assert.Contains(t, deps[0].Load(config), "patched by us")
+}
+
+func TestLockFileIdentity(t *testing.T) {
+	config := &Config{Path: t.TempDir(), Title: "Title", Report: &Report{}}
+	github := Dependency{Name: "a/x", FullName: "github.com/a/x", License: "MIT", LicenseText: mitLicense, DependencyType: GoDep}
+	config.Lock = &Lock{Dependencies: []LockEntry{{Identity: github.Identity(), Name: "a/x", StanzaHash: textHash("## a/x\n\nGitHub")}}}
+	writeTestFile(t, config.NoticeFilePath(), "Title\n\n--------\n\n## a/x\n\nGitHub\n\n")
+
+	// The stanza is split under the identity recorded in the lock
+	require.NoError(t, SplitExistingNotice(config))
+	assert.True(t, HasExistingNotice(config, github.FileName()))
+	assert.False(t, HasExistingNotice(config, "a-x"))
+
+	// A package known to the lock does not take a stanza of the same name
+	writeTestFile(t, config.NoticeDirPath()+"/a-x", "## a/x\n\nOther\n\n")
+	require.NoError(t, os.Remove(config.NoticeDirPath()+"/"+github.FileName()))
+	_, ok := github.existingNotice(config)
+	assert.False(t, ok)
+}
+
+func TestReadLockInvalid(t *testing.T) {
+	dir := t.TempDir()
+	lock, err := ReadLock(dir + "/missing.json")
+	assert.NoError(t, err)
+	assert.Empty(t, lock.Dependencies)
+
+	writeTestFile(t, dir+"/invalid.json", "{")
+	_, err = ReadLock(dir + "/invalid.json")
+	assert.Error(t, err)
+
+	writeTestFile(t, dir+"/future.json", `{"lockfileVersion": 2, "dependencies": []}`)
+	_, err = ReadLock(dir + "/future.json")
+	assert.Error(t, err)
+}
@@ -1,13 +1,15 @@
 package main
 
 import (
-	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
 	"regexp"
-	"sync"
+	"syscall"
 )
 
 func IndexOf[T comparable](collection []T, el T) int {
@@ -24,69 +26,108 @@ func GenerateFileName(name string) string {
 	return reg.ReplaceAllString(name, "-")
 }
 
-func HTTPGet(rsc string) (string, error) {
-	out := &bytes.Buffer{}
-
-	client := http.Client{}
-
-	req, err := http.NewRequest("GET", rsc, nil)
-	if err != nil {
-		return "", err
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("http status code %d when downloading %q", resp.StatusCode, rsc)
+func main() {
+	config := newConfig()
+	var transport http.RoundTripper = http.DefaultTransport
+	if config.Offline {
+		log.Printf("Running offline, only cached metadata and local sources are used")
+		transport = offlineTransport{}
 	}
+	httpClient = NewHTTPClient(transport, config.RequestTimeout, config.GHToken != "" || config.GHEnterpriseToken != "")
+	cache := &Cache{Dir: config.CacheDir, TTL: config.CacheTTL, Refresh: config.Refresh, Offline: config.Offline}
+	httpClient.Transport = &CacheTransport{Base: httpClient.Transport, Cache: cache}
+	defer cache.LogStatistics()
 
-	_, err = io.Copy(out, resp.Body)
-	if err != nil {
-		return "", err
+	var err error
+	if config.GitHub, err = NewGitHub(httpClient, config.GHToken, config.GHEnterpriseURL, config.GHEnterpriseUploadURL, config.GHEnterpriseToken); err != nil {
+		log.Fatalf("Error occured while creating the GitHub client: %v", err)
 	}
 
-	return out.String(), nil
-}
-
-func main() {
-	config := newConfig()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, config.Timeout)
+	defer cancel()
 
 	log.Printf("Processing repo %s", config.Name)
-	var err error
 	var dependencies []Dependency
 
+	if config.Lock, err = ReadLock(config.LockFilePath()); err != nil {
+		log.Printf("Error occured while reading the lock file, stanzas are reused as in NOTICE.txt: %v", err)
+	}
 	if err = SplitExistingNotice(config); err != nil {
 		log.Printf("Error occured while splitting existing notice.txt %s:%v", config.Name, err)
 	}
 
-	if dependencies, err = PopulateDependencies(config); err != nil {
+	if dependencies, err = PopulateDependencies(ctx, config); err != nil {
+		abort(ctx, config)
 		log.Fatalf("Error occured while populating dependencies %s:%v", config.Name, err)
 	}
+	config.CheckRefreshList(dependencies)
 	if err = CreateNoticeDir(config); err != nil {
 		log.Fatalf("Error occured while creating work folder %s:%v", config.Name, err)
 	}
 
-	var wg sync.WaitGroup
-
-	for _, d := range dependencies {
-		wg.Add(1)
-		d := d
-		go func() {
-			defer wg.Done()
-
-			if err = d.Generate(config); err != nil {
-				log.Fatalf("Error occured while generating notice.txt %s:%v", d.Name, err)
+	if !config.Offline {
+		var pending []Dependency
+		for _, d := range dependencies {
+			if _, ok := d.existingNotice(config); !ok || config.RefreshReason(&d) != "" {
+				pending = append(pending, d)
 			}
+		}
+		config.GitHub.Prefetch(ctx, pending)
+	}
 
-		}()
+	err = ForEach(ctx, config.Concurrency, dependencies, func(ctx context.Context, d Dependency) {
+		if err := d.Generate(ctx, config); err != nil {
+			log.Printf("Error occured while generating notice.txt %s:%v", d.Name, err)
+			stage := StageGenerate
+			if errors.Is(err, errOffline) {
+				stage = StageOffline
+			}
+			config.Report.AddFailure(d.Name, d.DependencyType, stage, err)
+		}
+	})
+	if err != nil {
+		abort(ctx, config)
+		log.Fatalf("Error occured while generating notice.txt %s:%v", config.Name, err)
+	}
+	if summary := config.Report.MissingNoticeSummary(); summary != "" {
+		log.Print(summary)
+	}
+	if summary := config.Report.MissingLicenseTextSummary(); summary != "" {
+		log.Print(summary)
+	}
+	if summary := config.Report.RefreshSummary(); summary != "" {
+		log.Print(summary)
 	}
-	wg.Wait()
 
 	if err = UpdateNotice(config, dependencies); err != nil {
 		log.Fatalf("Error occured while updating notice.txt %s:%v", config.Name, err)
 	}
+	if err = WriteLock(config, dependencies); err != nil {
+		log.Printf("Error occured while writing the lock file %s:%v", config.LockFilePath(), err)
+	}
+
+	if config.ReportFile != "" {
+		if err = config.Report.WriteFile(config.ReportFile); err != nil {
+			log.Printf("Error occured while writing report %s:%v", config.ReportFile, err)
+		}
+	}
+	if config.Report.HasFailures() {
+		cache.LogStatistics()
+		fmt.Fprint(os.Stderr, config.Report.Summary())
+		os.Exit(exitCodeIncomplete)
+	}
+}
+
+// abort removes the work folder when the run was interrupted or timed out, so
+// no half generated stanzas are left behind. NOTICE.txt is left untouched.
+func abort(ctx context.Context, config *Config) {
+	if ctx.Err() == nil {
+		return
+	}
+	log.Printf("Run aborted (%v), removing %s", context.Cause(ctx), config.NoticeWorkPath())
+	if err := os.RemoveAll(config.NoticeWorkPath()); err != nil {
+		log.Printf("Error while removing %s: %v", config.NoticeWorkPath(), err)
+	}
 }
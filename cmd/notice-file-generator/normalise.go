@@ -0,0 +1,168 @@
+package main
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	regexpHTMLComment = regexp.MustCompile(`(?s)<!--.*?-->`)
+	regexpHTMLBreak   = regexp.MustCompile(`(?i)<br\s*/?>\n?`)
+	// regexpHTMLTag only matches the tags found in README-like license files,
+	// so placeholders such as <year> and links such as <https://fsf.org/> stay
+	regexpHTMLTag = regexp.MustCompile(`(?i)</?(?:a|abbr|b|blockquote|center|code|details|div|em|h[1-6]|hr|i|img|li|ol|p|pre|small|span|strong|sub|summary|sup|table|tbody|td|th|thead|tr|u|ul)(?:\s[^<>]*)?/?>`)
+
+	regexpMarkdownHeading = regexp.MustCompile(`(?m)^ {0,3}#{1,6}[ \t]+(.*?)(?:[ \t]+#+)?[ \t]*$`)
+	regexpMarkdownRule    = regexp.MustCompile(`(?m)^ {0,3}(?:[-=*_][ \t]*){3,}$`)
+	regexpMarkdownFence   = regexp.MustCompile("(?m)^ {0,3}(?:```|~~~).*$")
+	regexpMarkdownBadge   = regexp.MustCompile(`\[!\[[^\]]*\]\([^)]*\)\]\([^)]*\)|!\[[^\]]*\]\([^)]*\)`)
+	regexpMarkdownLink    = regexp.MustCompile(`\[([^\]\n]+)\]\(([^)\s]+)(?:\s+"[^"]*")?\)`)
+	regexpMarkdownStrong  = regexp.MustCompile(`\*\*([^*\n]+)\*\*|__([^_\n]+)__`)
+	// regexpMarkdownEmph spans lines, but not paragraphs, and never starts or
+	// ends next to a space so list bullets are left alone
+	regexpMarkdownEmph = regexp.MustCompile(`(^|[^\w*])\*([^*\s](?:[^*]*?[^*\s])?)\*($|[^\w*])`)
+
+	regexpListItem = regexp.MustCompile(`^\s*(?:[-*+]|[0-9]+[.)]|\([a-zA-Z0-9]+\))\s+`)
+)
+
+// NormaliseLicenseText turns a fetched license file into plain text for
+// NOTICE.txt: the BOM and CR are removed, Markdown and HTML are rendered as
+// text, and runs of blank lines collapsed. Rules are only removed from Markdown. Paragraphs are re-wrapped when
+// width is positive.
+func NormaliseLicenseText(text string, width int) string {
+	text = strings.ReplaceAll(text, "\ufeff", "")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	markdown := isMarkdown(text)
+
+	text = regexpHTMLComment.ReplaceAllString(text, "")
+	text = regexpHTMLBreak.ReplaceAllString(text, "\n")
+	text = regexpHTMLTag.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+
+	// The rules of plain text licenses, ie. under the section titles of the
+	// MPL, are part of their layout
+	if markdown {
+		text = regexpMarkdownRule.ReplaceAllString(text, "")
+	}
+	text = regexpMarkdownFence.ReplaceAllString(text, "")
+	text = regexpMarkdownHeading.ReplaceAllString(text, "$1")
+	text = regexpMarkdownBadge.ReplaceAllString(text, "")
+	text = regexpMarkdownLink.ReplaceAllStringFunc(text, func(link string) string {
+		m := regexpMarkdownLink.FindStringSubmatch(link)
+		if m[1] == m[2] || strings.TrimPrefix(m[2], "mailto:") == m[1] {
+			return m[1]
+		}
+		return m[1] + " (" + m[2] + ")"
+	})
+	text = regexpMarkdownStrong.ReplaceAllString(text, "$1$2")
+	text = regexpMarkdownEmph.ReplaceAllStringFunc(text, func(emphasis string) string {
+		m := regexpMarkdownEmph.FindStringSubmatch(emphasis)
+		if strings.Contains(m[2], "\n\n") {
+			return emphasis
+		}
+		return m[1] + m[2] + m[3]
+	})
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	text = regexpBlankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	text = strings.Trim(text, "\n")
+	if width > 0 {
+		text = wrapParagraphs(text, width)
+	}
+	return text
+}
+
+// isMarkdown reports whether a license text uses Markdown or HTML, ie. has
+// headings, links or tags, rather than being plain text.
+func isMarkdown(text string) bool {
+	for _, r := range []*regexp.Regexp{regexpMarkdownHeading, regexpMarkdownFence, regexpMarkdownBadge, regexpMarkdownLink, regexpHTMLTag} {
+		if r.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// wrapParagraphs re-wraps the paragraphs of a text to width. List items and
+// copyright lines are wrapped on their own, the continuation lines of an item
+// being aligned on its text, and indented paragraphs, ie. code or addresses,
+// are kept as is.
+func wrapParagraphs(text string, width int) string {
+	paragraphs := strings.Split(text, "\n\n")
+	for i, paragraph := range paragraphs {
+		lines := strings.Split(paragraph, "\n")
+		if isPreformatted(lines) {
+			continue
+		}
+		var wrapped, item []string
+		lead, indent := "", ""
+		flush := func() {
+			if len(item) > 0 {
+				wrapped = append(wrapped, wrapWords(strings.Fields(strings.Join(item, " ")), width, lead, indent)...)
+			}
+			item = nil
+		}
+		for _, line := range lines {
+			_, copyright := parseCopyright(line)
+			if m := regexpListItem.FindString(line); m != "" || copyright || len(item) == 0 {
+				flush()
+				lead = line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+				indent = strings.Repeat(" ", len(m))
+			}
+			item = append(item, line)
+			if copyright {
+				flush()
+			}
+		}
+		flush()
+		paragraphs[i] = strings.Join(wrapped, "\n")
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
+
+// isPreformatted reports whether the lines of a paragraph are laid out by
+// hand, ie. indented other than by a list item, or underlined or boxed by the
+// rules of a plain text license.
+func isPreformatted(lines []string) bool {
+	for _, line := range lines {
+		if (strings.HasPrefix(line, "  ") || strings.HasPrefix(line, "\t")) && !regexpListItem.MatchString(line) {
+			return true
+		}
+		if regexpMarkdownRule.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// wrapWords lays out words in lines of at most width characters, words
+// longer than width being kept whole. The first line starts with lead, the
+// others with indent.
+func wrapWords(words []string, width int, lead, indent string) []string {
+	var lines []string
+	line := strings.Builder{}
+	line.WriteString(lead)
+	empty := true
+	for _, word := range words {
+		switch {
+		case empty:
+			line.WriteString(word)
+			empty = false
+		case line.Len()+1+len(word) > width:
+			lines = append(lines, line.String())
+			line.Reset()
+			line.WriteString(indent + word)
+		default:
+			line.WriteString(" " + word)
+		}
+	}
+	if !empty {
+		lines = append(lines, line.String())
+	}
+	return lines
+}
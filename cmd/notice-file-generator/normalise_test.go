@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files of testdata")
+
+func TestNormaliseLicenseText(t *testing.T) {
+	cases := []struct {
+		file  string
+		width int
+	}{
+		{"markdown-mit.md", 0},
+		{"html-isc.md", 0},
+		{"gpl-notice.txt", 60},
+		{"long-lines.md", 0},
+		{"long-lines.md", 72},
+		// Real license files
+		{"blue-oak.md", 0},
+		{"minipass-fetch.txt", 0},
+		{"mpl-2.0.txt", 0},
+		{"mpl-2.0.txt", 72},
+		{"tslib-crlf.txt", 0},
+		{"tslib-crlf.txt", 60},
+	}
+	for _, c := range cases {
+		input, err := os.ReadFile(filepath.Join("testdata", "licenses", c.file))
+		require.NoError(t, err)
+		golden := filepath.Join("testdata", "licenses", fmt.Sprintf("%s.%d.golden", c.file, c.width))
+		got := NormaliseLicenseText(string(input), c.width)
+		if *updateGolden {
+			require.NoError(t, os.WriteFile(golden, []byte(got+"\n"), 0644))
+		}
+		want, err := os.ReadFile(golden)
+		require.NoError(t, err)
+		assert.Equal(t, string(want), got+"\n", golden)
+
+		assert.NotContains(t, got, "\r", c.file)
+		assert.NotContains(t, got, "\ufeff", c.file)
+		assert.NotContains(t, got, "\n\n\n", c.file)
+		if c.width > 0 {
+			for _, line := range strings.Split(got, "\n") {
+				// Indented paragraphs and long words are kept as is
+				if !strings.HasPrefix(line, "  ") && strings.Contains(line, " ") {
+					assert.LessOrEqual(t, len(line), c.width, line)
+				}
+			}
+		}
+		// Normalising is idempotent
+		assert.Equal(t, got, NormaliseLicenseText(got, c.width), c.file)
+	}
+}
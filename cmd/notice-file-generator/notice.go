@@ -10,6 +10,10 @@ import (
 	"strings"
 )
 
+// containerSectionHeading starts the section listing base images and OS
+// packages of the distributed container images.
+const containerSectionHeading = "CONTAINER COMPONENTS:"
+
 func CreateNoticeDir(config *Config) error {
 	if _, err := os.Stat(config.NoticeWorkPath()); os.IsExist(err) {
 		os.RemoveAll(config.NoticeWorkPath())
@@ -46,26 +50,67 @@ func UpdateNotice(config *Config, dependencies []Dependency) error {
 		log.Printf("Error while writing string %v", err)
 	}
 
+	// The identity orders the packages sharing a display name
 	sort.Slice(dependencies, func(i, j int) bool {
-		return dependencies[i].Name < dependencies[j].Name
+		if dependencies[i].Name != dependencies[j].Name {
+			return dependencies[i].Name < dependencies[j].Name
+		}
+		return dependencies[i].Identity() < dependencies[j].Identity()
 	})
 
-	idx := 0
+	var mainDeps, containerDeps []Dependency
 	for _, d := range dependencies {
-		if idx > 0 {
-			if _, err = writer.WriteString("---\n\n"); err != nil {
-				log.Printf("Error while writing string %v", err)
-			}
+		if d.DependencyType == ContainerDep {
+			containerDeps = append(containerDeps, d)
+		} else {
+			mainDeps = append(mainDeps, d)
 		}
-		if _, err = writer.WriteString(d.Load(config)); err != nil {
+	}
+
+	stanzas := writeStanzas(writer, config, mainDeps)
+	if len(containerDeps) > 0 {
+		if _, err = writer.WriteString(fmt.Sprintf("--------\n\n%s\n--------\n\n", containerSectionHeading)); err != nil {
+			log.Printf("Error while writing string %v", err)
+		}
+		stanzas = append(stanzas, writeStanzas(writer, config, containerDeps)...)
+	}
+
+	// Stanzas reused from a previous run may reference the appendix even
+	// when licenseAppendix was disabled since
+	if texts := licenseTextAppendix(config, stanzas); len(texts) > 0 {
+		if _, err = writer.WriteString(fmt.Sprintf("--------\n\n%s\n--------\n\n", licenseTextsHeading)); err != nil {
+			log.Printf("Error while writing string %v", err)
+		}
+		if _, err = writer.WriteString(strings.Join(texts, "---\n\n")); err != nil {
 			log.Printf("Error while writing string %v", err)
 		}
-		idx = idx + 1
 	}
 	writer.Flush()
 	return nil
 }
 
+// writeStanzas writes the stanzas of the dependencies separated by rules and
+// returns them. Dependencies that failed to generate have no stanza.
+func writeStanzas(writer *bufio.Writer, config *Config, dependencies []Dependency) []string {
+	var stanzas []string
+	for _, d := range dependencies {
+		stanza := d.Load(config)
+		if stanza == "" {
+			continue
+		}
+		if len(stanzas) > 0 {
+			if _, err := writer.WriteString("---\n\n"); err != nil {
+				log.Printf("Error while writing string %v", err)
+			}
+		}
+		if _, err := writer.WriteString(stanza); err != nil {
+			log.Printf("Error while writing string %v", err)
+		}
+		stanzas = append(stanzas, stanza)
+	}
+	return stanzas
+}
+
 func SplitExistingNotice(config *Config) error {
 	noticeDir := config.NoticeDirPath()
 	if _, err := os.Stat(noticeDir); os.IsNotExist(err) {
@@ -79,13 +124,39 @@ func SplitExistingNotice(config *Config) error {
 
 		defer file.Close()
 
+		var lines []string
 		scanner := bufio.NewScanner(file)
-		var out *os.File
-		var writer *bufio.Writer
 		for scanner.Scan() {
-			line := scanner.Text()
+			lines = append(lines, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
 
-			if strings.HasPrefix(line, "## ") {
+		var out *os.File
+		var writer *bufio.Writer
+		inAppendix := false
+		for i, line := range lines {
+			if line == licenseTextsHeading {
+				inAppendix = true
+				if err = os.MkdirAll(licenseTextsPath(noticeDir), os.ModePerm); err != nil {
+					return err
+				}
+			}
+			if inAppendix && strings.HasPrefix(line, "### [") && strings.HasSuffix(line, "]") {
+				if writer != nil {
+					writer.Flush()
+					out.Close()
+				}
+				anchor := GenerateFileName(strings.TrimSuffix(strings.TrimPrefix(line, "### ["), "]"))
+				out, err = os.OpenFile(filepath.Join(licenseTextsPath(noticeDir), anchor), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+				if err != nil {
+					return err
+				}
+				writer = bufio.NewWriter(out)
+				continue
+			}
+			if !inAppendix && strings.HasPrefix(line, "## ") {
 				if writer != nil {
 					writer.Flush()
 					out.Close()
@@ -94,14 +165,19 @@ func SplitExistingNotice(config *Config) error {
 				}
 				name := strings.Replace(line, "## ", "", -1)
 				log.Printf("Found %s in existing notice.txt", name)
-				out, err = os.OpenFile(noticeDir+"/"+GenerateFileName(name), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+				// The lock tells which package the stanza belongs to
+				filename := GenerateFileName(name)
+				if entry, ok := config.Lock.entryByName(name); ok {
+					filename = GenerateFileName(entry.Identity)
+				}
+				out, err = os.OpenFile(noticeDir+"/"+filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
 				if err != nil {
 					return err
 				}
 				writer = bufio.NewWriter(out)
 			}
 			if out != nil {
-				if line == "---" {
+				if isStanzaSeparator(lines, i) || line == containerSectionHeading || line == licenseTextsHeading {
 					writer.Flush()
 					out.Close()
 					writer = nil
@@ -117,20 +193,28 @@ func SplitExistingNotice(config *Config) error {
 			writer.Flush()
 			out.Close()
 		}
-		if err := scanner.Err(); err != nil {
-			return err
-		}
+		config.Lock.markEdited(noticeDir)
 	}
 	return nil
 
 }
 
-func MoveExistingNotice(config *Config, filename string) error {
-	oldLocation := filepath.Join(config.NoticeDirPath(), filename)
-	newLocation := filepath.Join(config.NoticeWorkPath(), filename)
-	err := os.Rename(oldLocation, newLocation)
-	if err != nil {
-		return err
+// isStanzaSeparator reports whether lines[i] is a rule written between two
+// stanzas, appendix texts or sections, ie. followed by a blank line and their
+// heading. The rules of the license texts themselves are kept.
+func isStanzaSeparator(lines []string, i int) bool {
+	if lines[i] != "---" && lines[i] != "--------" {
+		return false
 	}
-	return nil
+	if i+2 >= len(lines) || lines[i+1] != "" {
+		return false
+	}
+	next := lines[i+2]
+	return strings.HasPrefix(next, "## ") || strings.HasPrefix(next, "### [") || next == containerSectionHeading || next == licenseTextsHeading
+}
+
+// HasExistingNotice reports whether a stanza of a previous run can be reused.
+func HasExistingNotice(config *Config, filename string) bool {
+	_, err := os.Stat(filepath.Join(config.NoticeDirPath(), filename))
+	return err == nil
 }
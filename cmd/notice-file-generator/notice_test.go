@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitExistingNoticeKeepsRules(t *testing.T) {
+	config := &Config{Path: t.TempDir(), Title: "Title", Report: &Report{}}
+	require.NoError(t, CreateNoticeDir(config))
+	require.NoError(t, os.MkdirAll(licenseTextsPath(config.NoticeWorkPath()), os.ModePerm))
+
+	alpha := "## alpha\n\nThis product contains 'alpha'.\n\n* LICENSE: Custom\n\nTerms\n---\n\nSection 1\n\n--------\n\nSection 2\n\n"
+	beta := "## beta\n\nThis product contains 'beta'.\n\n* LICENSE: Custom\n\n* LICENSE TEXT: see [LICENSE-0123abcd] in LICENSE TEXTS\n\n"
+	deps := []Dependency{
+		{Name: "alpha", DependencyType: ManualDep},
+		{Name: "beta", DependencyType: ManualDep},
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(config.NoticeWorkPath(), deps[0].FileName()), []byte(alpha), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(config.NoticeWorkPath(), deps[1].FileName()), []byte(beta), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(licenseTextsPath(config.NoticeWorkPath()), "LICENSE-0123abcd"), []byte("Appendix terms\n--------\n\nMore terms\n---\n"), 0644))
+	require.NoError(t, UpdateNotice(config, deps))
+	notice, err := os.ReadFile(config.NoticeFilePath())
+	require.NoError(t, err)
+
+	// The rules of the texts do not end their stanza or appendix text
+	require.NoError(t, SplitExistingNotice(config))
+	stanza, err := os.ReadFile(filepath.Join(config.NoticeDirPath(), "alpha"))
+	require.NoError(t, err)
+	assert.Equal(t, alpha, string(stanza))
+	text, err := os.ReadFile(filepath.Join(licenseTextsPath(config.NoticeDirPath()), "LICENSE-0123abcd"))
+	require.NoError(t, err)
+	assert.Equal(t, "Appendix terms\n--------\n\nMore terms\n---", strings.TrimSpace(string(text)))
+
+	require.NoError(t, os.RemoveAll(config.NoticeWorkPath()))
+	require.NoError(t, CreateNoticeDir(config))
+	for _, d := range deps {
+		require.NoError(t, d.ReuseExistingNotice(config))
+	}
+	require.NoError(t, UpdateNotice(config, deps))
+	regenerated, err := os.ReadFile(config.NoticeFilePath())
+	require.NoError(t, err)
+	assert.Equal(t, string(notice), string(regenerated))
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/module"
+)
+
+// errOffline is returned for every request that is not in the cache when the
+// -offline flag is set.
+var errOffline = errors.New("network access is disabled in offline mode")
+
+// offlineTransport forbids all network access.
+type offlineTransport struct{}
+
+func (offlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, fmt.Errorf("%w: %s", errOffline, req.URL)
+}
+
+// GoModCacheDir returns the Go module cache location, following the same
+// rules as the go command.
+func GoModCacheDir() string {
+	if dir := os.Getenv("GOMODCACHE"); dir != "" {
+		return dir
+	}
+	gopath := os.Getenv("GOPATH")
+	if gopath == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		gopath = filepath.Join(home, "go")
+	}
+	return filepath.Join(filepath.SplitList(gopath)[0], "pkg", "mod")
+}
+
+// GoRootDir returns the root of the Go installation, whose LICENSE is the one
+// of the Go dependency.
+func GoRootDir() string {
+	if dir := os.Getenv("GOROOT"); dir != "" {
+		return dir
+	}
+	out, err := exec.Command("go", "env", "GOROOT").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// guessGoImport derives the repository of a module from its path, for when
+// the go-import meta tags cannot be fetched.
+func guessGoImport(modulePath string) GoImport {
+	parts := strings.Split(modulePath, "/")
+	prefix := modulePath
+	switch parts[0] {
+	case "github.com", "gitlab.com", "bitbucket.org", "codeberg.org":
+		if len(parts) >= 3 {
+			prefix = strings.Join(parts[:3], "/")
+		}
+	case "golang.org":
+		if len(parts) >= 3 && parts[1] == "x" {
+			return GoImport{ImportPrefix: strings.Join(parts[:3], "/"), Vcs: "git", RepoRoot: "https://go.googlesource.com/" + parts[2]}
+		}
+	}
+	return GoImport{ImportPrefix: prefix, Vcs: "git", RepoRoot: "https://" + prefix}
+}
+
+// LoadFromModuleCache reads the license of a Go dependency from the module
+// cache, as filled by go mod download.
+func (d *Dependency) LoadFromModuleCache(config *Config) error {
+	if d.FullName == "" || d.Version == "" {
+		return fmt.Errorf("%w: no module path and version for %s", errOffline, d.Name)
+	}
+	escapedPath, err := module.EscapePath(d.FullName)
+	if err != nil {
+		return err
+	}
+	escapedVersion, err := module.EscapeVersion(d.Version)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(config.GoModCache, escapedPath+"@"+escapedVersion)
+	text, err := readLicenseFiles(dir)
+	if err != nil {
+		return fmt.Errorf("%w: %s@%s is not in the module cache", errOffline, d.FullName, d.Version)
+	}
+	log.Printf("Loaded %s from the module cache", d.Name)
+	if d.HomePage == "" {
+		d.HomePage = strings.TrimSuffix(d.Repository.URL, ".git")
+	}
+	if text != "" {
+		d.LicenseText = text
+		if d.License == "" {
+			d.License = IdentifyLicense(text)
+		}
+	}
+	d.NoticeText = readNoticeFile(dir)
+	d.loadCopyrights(dir)
+	return nil
+}
+
+// LoadFromGoRoot reads the license of the Go dependency from the Go
+// installation, as the Go repository is not a module of the module cache.
+// Without it the embedded BSD-3-Clause text is used.
+func (d *Dependency) LoadFromGoRoot(config *Config) error {
+	d.License = "BSD-3-Clause"
+	if config.GoRoot == "" {
+		return nil
+	}
+	text, err := readLicenseFiles(config.GoRoot)
+	if err != nil || text == "" {
+		return nil
+	}
+	log.Printf("Loaded %s from %s", d.Name, config.GoRoot)
+	d.LicenseText = text
+	d.loadCopyrights("")
+	return nil
+}
+
+// LoadFromNodeModules reads the package.json and license of an npm dependency
+// installed next to the package.json declaring it.
+func (d *Dependency) LoadFromNodeModules() error {
+	if d.Manifest == "" {
+		return fmt.Errorf("%w: no package.json for %s", errOffline, d.Name)
+	}
+	dir := filepath.Join(filepath.Dir(d.Manifest), "node_modules", filepath.FromSlash(d.Name))
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return fmt.Errorf("%w: %s is not installed in node_modules", errOffline, d.Name)
+	}
+	if err = d.unmarshalNpm(data); err != nil {
+		return err
+	}
+	log.Printf("Loaded %s from node_modules", d.Name)
+	if text, err := readLicenseFiles(dir); err == nil && text != "" {
+		d.LicenseText = text
+	}
+	d.NoticeText = readNoticeFile(dir)
+	d.loadCopyrights(dir)
+	return nil
+}
+
+// loadOffline fills in the dependency from local sources after the cached
+// metadata, if any, was loaded.
+func (d *Dependency) loadOffline(config *Config, loadErr error) error {
+	var err error
+	switch d.DependencyType {
+	case JsDep:
+		err = d.LoadFromNodeModules()
+	case GoDep:
+		if d.FullName == goRepository {
+			err = d.LoadFromGoRoot(config)
+		} else {
+			err = d.LoadFromModuleCache(config)
+		}
+	default:
+		return loadErr
+	}
+	// Cached metadata with a local license is as good as it gets
+	if loadErr == nil {
+		return nil
+	}
+	return err
+}
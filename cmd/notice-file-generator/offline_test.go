@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func useOfflineHTTPClient(t *testing.T) {
+	cache := &Cache{Dir: t.TempDir(), TTL: time.Hour, Offline: true}
+	useHTTPClient(t, &http.Client{Transport: &CacheTransport{Base: NewHTTPClient(offlineTransport{}, time.Second, false).Transport, Cache: cache}})
+}
+
+func TestGuessGoImport(t *testing.T) {
+	assert.Equal(t, GoImport{ImportPrefix: "github.com/mattermost/mattermost", Vcs: "git", RepoRoot: "https://github.com/mattermost/mattermost"}, guessGoImport("github.com/mattermost/mattermost/server/v8"))
+	assert.Equal(t, GoImport{ImportPrefix: "golang.org/x/mod", Vcs: "git", RepoRoot: "https://go.googlesource.com/mod"}, guessGoImport("golang.org/x/mod"))
+	assert.Equal(t, GoImport{ImportPrefix: "gopkg.in/yaml.v3", Vcs: "git", RepoRoot: "https://gopkg.in/yaml.v3"}, guessGoImport("gopkg.in/yaml.v3"))
+}
+
+func TestOfflineTransport(t *testing.T) {
+	useOfflineHTTPClient(t)
+	_, err := HTTPGet(context.Background(), "https://registry.npmjs.org/react")
+	assert.True(t, errors.Is(err, errOffline))
+}
+
+func TestLoadFromModuleCache(t *testing.T) {
+	config := &Config{GoModCache: t.TempDir()}
+	writeTestFile(t, filepath.Join(config.GoModCache, "github.com/!burnt!sushi/toml@v1.3.2/COPYING"), mitLicense)
+
+	dep := Dependency{Name: "BurntSushi/toml", FullName: "github.com/BurntSushi/toml", Version: "v1.3.2", Repository: DependencyRepository{URL: "https://github.com/BurntSushi/toml"}, DependencyType: GoDep}
+	assert.NoError(t, dep.LoadFromModuleCache(config))
+	assert.Equal(t, "MIT", dep.License)
+	assert.Equal(t, "https://github.com/BurntSushi/toml", dep.HomePage)
+	assert.Contains(t, dep.LicenseText, "Permission is hereby granted")
+
+	dep = Dependency{Name: "other/mod", FullName: "github.com/other/mod", Version: "v1.0.0", DependencyType: GoDep}
+	assert.True(t, errors.Is(dep.LoadFromModuleCache(config), errOffline))
+}
+
+func TestLoadFromGoRoot(t *testing.T) {
+	config := &Config{GoRoot: t.TempDir()}
+	writeTestFile(t, filepath.Join(config.GoRoot, "LICENSE"), "Copyright 2009 The Go Authors.\n\nRedistribution and use in source and binary forms, with or without\nmodification, are permitted. Neither the name of Google LLC nor the names of its contributors.\n")
+
+	dep := Dependency{Name: "Go", FullName: goRepository, Version: "1.22", DependencyType: GoDep}
+	assert.NoError(t, dep.loadOffline(config, errOffline))
+	assert.Equal(t, "BSD-3-Clause", dep.License)
+	assert.Contains(t, dep.LicenseText, "Copyright 2009 The Go Authors.")
+	assert.Equal(t, []Copyright{{Years: "2009", Holder: "The Go Authors"}}, dep.Copyrights)
+
+	// Without a Go installation the embedded text is used
+	dep = Dependency{Name: "Go", FullName: goRepository, Version: "1.22", DependencyType: GoDep}
+	assert.NoError(t, dep.loadOffline(&Config{}, errOffline))
+	assert.Equal(t, "BSD-3-Clause", dep.License)
+	assert.Empty(t, dep.LicenseText)
+}
+
+func TestLoadFromNodeModules(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "node_modules/@scope/pkg/package.json"), `{
+  "name": "@scope/pkg",
+  "description": "A scoped package",
+  "author": "Jane Doe <jane@example.com>",
+  "license": "MIT",
+  "repository": "github:scope/pkg"
+}`)
+	writeTestFile(t, filepath.Join(root, "node_modules/@scope/pkg/LICENSE"), mitLicense)
+
+	dep := Dependency{Name: "@scope/pkg", Manifest: filepath.Join(root, "package.json"), DependencyType: JsDep}
+	assert.NoError(t, dep.LoadFromNodeModules())
+	assert.Equal(t, "A scoped package", dep.Description)
+	assert.Equal(t, "MIT", dep.License)
+	assert.Contains(t, dep.LicenseText, "Permission is hereby granted")
+
+	dep = Dependency{Name: "missing", Manifest: filepath.Join(root, "package.json"), DependencyType: JsDep}
+	assert.True(t, errors.Is(dep.LoadFromNodeModules(), errOffline))
+}
+
+func TestGenerateOffline(t *testing.T) {
+	useOfflineHTTPClient(t)
+	config := &Config{Path: t.TempDir(), GoModCache: t.TempDir(), Offline: true, Report: &Report{}}
+	require.NoError(t, CreateNoticeDir(config))
+	writeTestFile(t, filepath.Join(config.GoModCache, "github.com/foo/bar@v1.0.0/LICENSE"), mitLicense)
+
+	dep := Dependency{Name: "foo/bar", FullName: "github.com/foo/bar", Version: "v1.0.0", Repository: DependencyRepository{URL: "https://github.com/foo/bar"}, DependencyType: GoDep}
+	require.NoError(t, dep.Generate(context.Background(), config))
+	stanza, err := os.ReadFile(filepath.Join(config.NoticeWorkPath(), dep.FileName()))
+	require.NoError(t, err)
+	assert.Contains(t, string(stanza), "* LICENSE: MIT")
+	assert.Contains(t, string(stanza), "This product contains 'foo/bar' by Jane Doe.")
+	assert.Contains(t, string(stanza), "Copyright (c) 2020 Jane Doe")
+
+	manual := Dependency{Name: "font", License: "Apache-2.0", DependencyType: ManualDep}
+	require.NoError(t, manual.Generate(context.Background(), config))
+	stanza, err = os.ReadFile(filepath.Join(config.NoticeWorkPath(), manual.FileName()))
+	require.NoError(t, err)
+	assert.Contains(t, string(stanza), "Apache License")
+	assert.False(t, config.Report.HasFailures())
+
+	unknown := Dependency{Name: "font2", License: "Proprietary", DependencyType: ManualDep}
+	require.NoError(t, unknown.Generate(context.Background(), config))
+	require.Len(t, config.Report.Failures, 1)
+	assert.Equal(t, StageOffline, config.Report.Failures[0].Stage)
+
+	golang := Dependency{Name: "Go", FullName: goRepository, Version: "1.22", Repository: DependencyRepository{URL: goRepository}, DependencyType: GoDep}
+	require.NoError(t, golang.Generate(context.Background(), config))
+	stanza, err = os.ReadFile(filepath.Join(config.NoticeWorkPath(), golang.FileName()))
+	require.NoError(t, err)
+	assert.Contains(t, string(stanza), "Neither the name of the copyright holder")
+	require.Len(t, config.Report.Failures, 1)
+
+	missing := Dependency{Name: "missing/mod", FullName: "github.com/missing/mod", Version: "v1.0.0", Repository: DependencyRepository{URL: "https://github.com/missing/mod"}, DependencyType: GoDep}
+	err = missing.Generate(context.Background(), config)
+	assert.True(t, errors.Is(err, errOffline))
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// defaultConcurrency is the number of dependencies processed in parallel when
+// the -concurrency flag is not set.
+const defaultConcurrency = 8
+
+// ForEach calls fn for every item using at most concurrency goroutines. Items
+// not yet started when ctx is cancelled are skipped and the context error is
+// returned.
+func ForEach[T any](ctx context.Context, concurrency int, items []T, fn func(ctx context.Context, item T)) error {
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+
+	queue := make(chan T)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency && i < len(items); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range queue {
+				fn(ctx, item)
+			}
+		}()
+	}
+
+feed:
+	for _, item := range items {
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			break feed
+		case queue <- item:
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	return ctx.Err()
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForEach(t *testing.T) {
+	var running, maxRunning int32
+	var mu sync.Mutex
+	seen := map[int]bool{}
+
+	items := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	err := ForEach(context.Background(), 3, items, func(ctx context.Context, i int) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		mu.Lock()
+		seen[i] = true
+		mu.Unlock()
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, seen, len(items))
+	assert.LessOrEqual(t, maxRunning, int32(3))
+}
+
+func TestForEachCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var processed int32
+
+	err := ForEach(ctx, 1, []int{1, 2, 3, 4}, func(ctx context.Context, i int) {
+		atomic.AddInt32(&processed, 1)
+		cancel()
+	})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, processed, int32(4))
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RefreshPolicy decides when a stanza of the previous NOTICE.txt is
+// regenerated instead of being reused.
+type RefreshPolicy string
+
+const (
+	// RefreshReuse always reuses the stanzas, the default
+	RefreshReuse RefreshPolicy = "reuse"
+	// RefreshVersion regenerates the stanzas of the upgraded dependencies
+	RefreshVersion RefreshPolicy = "version"
+	// RefreshAge regenerates the stanzas older than refreshAfterDays
+	RefreshAge RefreshPolicy = "age"
+)
+
+const defaultRefreshAfterDays = 90
+
+// errRefresh skips the reuse of a stanza selected for refresh.
+var errRefresh = errors.New("stanza selected for refresh")
+
+func (p *RefreshPolicy) UnmarshalYAML(value *yaml.Node) error {
+	switch policy := RefreshPolicy(value.Value); policy {
+	case "", RefreshReuse, RefreshVersion, RefreshAge:
+		*p = policy
+		return nil
+	}
+	return fmt.Errorf("invalid refreshPolicy %q, expected %s, %s or %s", value.Value, RefreshReuse, RefreshVersion, RefreshAge)
+}
+
+// parseRefreshList splits the -refresh argument into the dependencies to
+// refresh.
+func parseRefreshList(value string) []string {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// refreshRequested reports whether the dependency is listed in -refresh, by
+// name, full module path or identity.
+func (c *Config) refreshRequested(d *Dependency) bool {
+	for _, name := range c.RefreshDependencies {
+		if d.isNamed(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Dependency) isNamed(name string) bool {
+	return name == d.Name || (d.FullName != "" && name == d.FullName) || name == d.Identity()
+}
+
+// CheckRefreshList warns about the -refresh entries matching no dependency
+// or a manual stanza.
+func (c *Config) CheckRefreshList(dependencies []Dependency) {
+	for _, name := range c.RefreshDependencies {
+		found := false
+		for i := range dependencies {
+			if d := &dependencies[i]; d.isNamed(name) {
+				found = true
+				if entry, _ := c.Lock.Entry(d.Identity()); entry.Manual || d.DependencyType == 0 {
+					log.Printf("The stanza of %s is manual, it is not refreshed", d.Name)
+				}
+			}
+		}
+		if !found {
+			log.Printf("No dependency %s to refresh", name)
+		}
+	}
+}
+
+// RefreshReason returns why the stanza of the previous NOTICE.txt should be
+// regenerated, or "" when it is reused or there is none. Manual stanzas are
+// never refreshed, nor are the stanzas of plain additional dependencies which
+// the tool cannot generate.
+func (c *Config) RefreshReason(d *Dependency) string {
+	if _, ok := d.existingNotice(c); !ok {
+		return ""
+	}
+	entry, locked := c.Lock.Entry(d.Identity())
+	if (locked && entry.Manual) || d.DependencyType == 0 {
+		return ""
+	}
+	if c.refreshRequested(d) {
+		return "requested"
+	}
+	// Overrides are corrections, they apply to reused stanzas as well
+	if override, ok := c.OverrideFor(d); ok && override.Hash() != entry.OverrideHash {
+		return "override changed"
+	} else if !ok && entry.OverrideHash != "" {
+		return "override removed"
+	}
+	if d.MetadataHash != "" && d.MetadataHash != entry.MetadataHash {
+		return "metadata changed"
+	}
+	if !locked {
+		return ""
+	}
+	switch c.RefreshPolicy {
+	case RefreshVersion:
+		if d.Version != "" && entry.Version != d.Version {
+			if entry.Version == "" {
+				return fmt.Sprintf("version %s", d.Version)
+			}
+			return fmt.Sprintf("version %s to %s", entry.Version, d.Version)
+		}
+	case RefreshAge:
+		days := c.RefreshAfterDays
+		if days <= 0 {
+			days = defaultRefreshAfterDays
+		}
+		if age := time.Since(entry.GeneratedAt); age > time.Duration(days)*24*time.Hour {
+			return fmt.Sprintf("generated %d days ago", int(age.Hours()/24))
+		}
+	}
+	return ""
+}
+
+// refreshed records the outcome of the refresh of a stanza. When it failed the
+// stanza of the previous run is used, so an unreachable registry does not
+// remove it from NOTICE.txt.
+func (d *Dependency) refreshed(config *Config, reason string, err error) error {
+	if err == nil {
+		config.Report.AddRefreshed(d.Name, d.DependencyType, reason, nil)
+		if filename, ok := d.existingNotice(config); ok {
+			_ = os.Remove(filepath.Join(config.NoticeDirPath(), filename))
+		}
+		return nil
+	}
+	log.Printf("Refresh of %s failed, keeping the previous stanza: %v", d.Name, err)
+	config.Report.AddRefreshed(d.Name, d.DependencyType, reason, err)
+	return d.ReuseExistingNotice(config)
+}
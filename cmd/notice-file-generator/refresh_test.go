@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseRefreshList(t *testing.T) {
+	assert.Empty(t, parseRefreshList(""))
+	assert.Equal(t, []string{"react", "github.com/foo/bar"}, parseRefreshList("react, github.com/foo/bar,"))
+}
+
+func TestRefreshPolicyConfig(t *testing.T) {
+	var config Config
+	assert.NoError(t, yaml.Unmarshal([]byte("refreshPolicy: age\nrefreshAfterDays: 30\n"), &config))
+	assert.Equal(t, RefreshAge, config.RefreshPolicy)
+	assert.Equal(t, 30, config.RefreshAfterDays)
+	assert.Error(t, yaml.Unmarshal([]byte("refreshPolicy: sometimes\n"), &config))
+}
+
+func TestRefreshReason(t *testing.T) {
+	config := &Config{Path: t.TempDir()}
+	dep := Dependency{Name: "react", Version: "18.3.1", DependencyType: JsDep}
+	manual := Dependency{Name: "patched", Version: "2.0.0", DependencyType: JsDep}
+	plain := Dependency{Name: "wix"}
+	for _, d := range []Dependency{dep, manual, plain} {
+		writeTestFile(t, filepath.Join(config.NoticeDirPath(), d.FileName()), "## "+d.Name+"\n")
+	}
+	config.Lock = &Lock{Dependencies: []LockEntry{
+		{Identity: dep.Identity(), Version: "18.2.0", GeneratedAt: time.Now().Add(-40 * 24 * time.Hour)},
+		{Identity: manual.Identity(), Version: "1.0.0", GeneratedAt: time.Now().Add(-400 * 24 * time.Hour), Manual: true},
+	}}
+
+	assert.Equal(t, "", config.RefreshReason(&dep))
+
+	config.RefreshPolicy = RefreshVersion
+	assert.Equal(t, "version 18.2.0 to 18.3.1", config.RefreshReason(&dep))
+	assert.Equal(t, "", config.RefreshReason(&manual))
+
+	config.RefreshPolicy = RefreshAge
+	assert.Equal(t, "", config.RefreshReason(&dep))
+	config.RefreshAfterDays = 30
+	assert.Equal(t, "generated 40 days ago", config.RefreshReason(&dep))
+	assert.Equal(t, "", config.RefreshReason(&manual))
+
+	config.RefreshPolicy = RefreshReuse
+	config.RefreshDependencies = []string{"pkg:npm/react", "patched", "wix"}
+	assert.Equal(t, "requested", config.RefreshReason(&dep))
+	assert.Equal(t, "", config.RefreshReason(&manual))
+	assert.Equal(t, "", config.RefreshReason(&plain))
+
+	// Without stanza to reuse there is nothing to refresh
+	missing := Dependency{Name: "missing", DependencyType: JsDep}
+	config.RefreshDependencies = []string{"missing"}
+	assert.Equal(t, "", config.RefreshReason(&missing))
+}
+
+func TestRefreshReasonOverride(t *testing.T) {
+	config := &Config{Path: t.TempDir()}
+	dep := Dependency{Name: "widget", Version: "1.0.0", DependencyType: JsDep}
+	writeTestFile(t, filepath.Join(config.NoticeDirPath(), dep.FileName()), "## widget\n")
+	override := DependencyOverride{License: "MIT"}
+	config.Lock = &Lock{Dependencies: []LockEntry{{Identity: dep.Identity(), Version: "1.0.0"}}}
+
+	// Reused stanzas are regenerated whenever their override changes
+	config.Overrides = map[string]DependencyOverride{"widget": override}
+	assert.Equal(t, "override changed", config.RefreshReason(&dep))
+	config.Lock.Dependencies[0].OverrideHash = override.Hash()
+	assert.Equal(t, "", config.RefreshReason(&dep))
+	config.Overrides["widget"] = DependencyOverride{License: "Apache-2.0"}
+	assert.Equal(t, "override changed", config.RefreshReason(&dep))
+	config.Overrides = nil
+	assert.Equal(t, "override removed", config.RefreshReason(&dep))
+}
+
+func TestGenerateRefresh(t *testing.T) {
+	useOfflineHTTPClient(t)
+	config := &Config{Path: t.TempDir(), Report: &Report{}, RefreshPolicy: RefreshVersion}
+	require.NoError(t, CreateNoticeDir(config))
+
+	widget := Dependency{Name: "widget", Version: "2.0.0", License: "MIT", LicenseText: mitLicense, DependencyType: ManualDep}
+	react := Dependency{Name: "react", Version: "18.3.1", DependencyType: JsDep}
+	config.Lock = &Lock{Dependencies: []LockEntry{
+		{Identity: widget.Identity(), Version: "1.0.0"},
+		{Identity: react.Identity(), Version: "18.2.0"},
+	}}
+	writeTestFile(t, filepath.Join(config.NoticeDirPath(), widget.FileName()), "## widget\n\nVersion 1.0.0\n")
+	writeTestFile(t, filepath.Join(config.NoticeDirPath(), react.FileName()), "## react\n\nVersion 18.2.0\n")
+
+	require.NoError(t, widget.Generate(context.Background(), config))
+	assert.Contains(t, widget.Load(config), "This product contains 'widget'")
+	assert.False(t, HasExistingNotice(config, widget.FileName()))
+
+	// The registry cannot be reached offline, the previous stanza is kept
+	require.NoError(t, react.Generate(context.Background(), config))
+	assert.Equal(t, "## react\n\nVersion 18.2.0\n", react.Load(config))
+
+	require.Len(t, config.Report.Refreshed, 2)
+	assert.Equal(t, RefreshedStanza{Name: "widget", Ecosystem: "manual", Reason: "version 1.0.0 to 2.0.0"}, config.Report.Refreshed[0])
+	assert.Equal(t, "version 18.2.0 to 18.3.1", config.Report.Refreshed[1].Reason)
+	assert.NotEmpty(t, config.Report.Refreshed[1].Error)
+
+	_, err := os.Stat(filepath.Join(config.NoticeWorkPath(), react.FileName()))
+	assert.NoError(t, err)
+}
+
+func TestGenerateRefreshAdditionalDependency(t *testing.T) {
+	config := &Config{Path: t.TempDir(), Title: "Title", Report: &Report{}}
+	additional := AdditionalDependency{Name: "widget", DependencyOverride: DependencyOverride{Author: "Jane Doe", License: "MIT"}}
+	generateNotice(t, config, []Dependency{additional.Dependency()})
+	notice, err := os.ReadFile(config.NoticeFilePath())
+	require.NoError(t, err)
+	assert.Contains(t, string(notice), "This product contains 'widget' by Jane Doe.")
+
+	// Editing the configured metadata regenerates the stanza
+	additional.Author = "Acme Corp"
+	additional.License = "Apache-2.0"
+	generateNotice(t, config, []Dependency{additional.Dependency()})
+	notice, err = os.ReadFile(config.NoticeFilePath())
+	require.NoError(t, err)
+	assert.Contains(t, string(notice), "This product contains 'widget' by Acme Corp.")
+	assert.Contains(t, string(notice), "* LICENSE: Apache-2.0")
+	require.Len(t, config.Report.Refreshed, 1)
+	assert.Equal(t, "metadata changed", config.Report.Refreshed[0].Reason)
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// exitCodeIncomplete is returned when NOTICE.txt was written but some
+// dependencies could not be processed and need a manual stanza.
+const exitCodeIncomplete = 2
+
+const (
+	StageManifest = "manifest"
+	StageResolve  = "resolve"
+	StageGenerate = "generate"
+	StageOffline  = "offline"
+)
+
+type DependencyFailure struct {
+	Name      string `json:"name"`
+	Ecosystem string `json:"ecosystem"`
+	Stage     string `json:"stage"`
+	Error     string `json:"error"`
+}
+
+type IgnoredDependency struct {
+	Name      string `json:"name"`
+	Ecosystem string `json:"ecosystem"`
+	Rule      string `json:"rule"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// MissingNotice is an Apache-2.0 dependency without a NOTICE file, which may
+// need to be checked by hand.
+type MissingNotice struct {
+	Name      string `json:"name"`
+	Ecosystem string `json:"ecosystem"`
+}
+
+// MissingLicenseText is a dependency whose stanza names a license without its
+// text, neither found upstream nor embedded in the tool.
+type MissingLicenseText struct {
+	Name      string `json:"name"`
+	Ecosystem string `json:"ecosystem"`
+	License   string `json:"license"`
+}
+
+// RefreshedStanza is a stanza of the previous NOTICE.txt regenerated by the
+// refresh policy. A failed refresh keeps the previous stanza.
+type RefreshedStanza struct {
+	Name      string `json:"name"`
+	Ecosystem string `json:"ecosystem"`
+	Reason    string `json:"reason"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Report collects what went wrong during a run. It is safe for concurrent use
+// and a nil report discards everything.
+type Report struct {
+	mu                  sync.Mutex
+	Failures            []DependencyFailure  `json:"failures"`
+	Ignored             []IgnoredDependency  `json:"ignored"`
+	MissingNotices      []MissingNotice      `json:"missingNotices"`
+	MissingLicenseTexts []MissingLicenseText `json:"missingLicenseTexts"`
+	Refreshed           []RefreshedStanza    `json:"refreshed"`
+}
+
+func (r *Report) AddFailure(name string, dependencyType DependencyType, stage string, err error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Failures = append(r.Failures, DependencyFailure{
+		Name:      name,
+		Ecosystem: dependencyType.String(),
+		Stage:     stage,
+		Error:     err.Error(),
+	})
+}
+
+func (r *Report) AddIgnored(ignored ...IgnoredDependency) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Ignored = append(r.Ignored, ignored...)
+}
+
+func (r *Report) AddMissingNotice(name string, dependencyType DependencyType) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.MissingNotices = append(r.MissingNotices, MissingNotice{Name: name, Ecosystem: dependencyType.String()})
+}
+
+func (r *Report) AddMissingLicenseText(name string, dependencyType DependencyType, license string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.MissingLicenseTexts = append(r.MissingLicenseTexts, MissingLicenseText{Name: name, Ecosystem: dependencyType.String(), License: license})
+}
+
+func (r *Report) AddRefreshed(name string, dependencyType DependencyType, reason string, err error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	refreshed := RefreshedStanza{Name: name, Ecosystem: dependencyType.String(), Reason: reason}
+	if err != nil {
+		refreshed.Error = err.Error()
+	}
+	r.Refreshed = append(r.Refreshed, refreshed)
+}
+
+func (r *Report) HasFailures() bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.Failures) > 0
+}
+
+// Summary lists the dependencies that need a manual stanza.
+func (r *Report) Summary() string {
+	if r == nil {
+		return ""
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	failures := append([]DependencyFailure(nil), r.Failures...)
+	sort.Slice(failures, func(i, j int) bool {
+		return failures[i].Name < failures[j].Name
+	})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d dependencies could not be processed and need a manual stanza in NOTICE.txt:\n", len(failures))
+	for _, f := range failures {
+		fmt.Fprintf(&b, "  - %s (%s) %s: %s\n", f.Name, f.Ecosystem, f.Stage, f.Error)
+	}
+	return b.String()
+}
+
+// MissingNoticeSummary lists the Apache-2.0 dependencies whose NOTICE file
+// could not be found.
+func (r *Report) MissingNoticeSummary() string {
+	if r == nil {
+		return ""
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.MissingNotices) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(r.MissingNotices))
+	for _, m := range r.MissingNotices {
+		names = append(names, fmt.Sprintf("%s (%s)", m.Name, m.Ecosystem))
+	}
+	sort.Strings(names)
+	return fmt.Sprintf("%d Apache-2.0 dependencies have no NOTICE file: %s", len(names), strings.Join(names, ", "))
+}
+
+// MissingLicenseTextSummary lists the stanzas naming a license without its
+// text, which may need to be completed by hand.
+func (r *Report) MissingLicenseTextSummary() string {
+	if r == nil {
+		return ""
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.MissingLicenseTexts) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(r.MissingLicenseTexts))
+	for _, m := range r.MissingLicenseTexts {
+		names = append(names, fmt.Sprintf("%s (%s) %s", m.Name, m.Ecosystem, m.License))
+	}
+	sort.Strings(names)
+	return fmt.Sprintf("%d dependencies have no license text: %s", len(names), strings.Join(names, ", "))
+}
+
+// RefreshSummary lists the stanzas regenerated by the refresh policy, and
+// those kept because their refresh failed.
+func (r *Report) RefreshSummary() string {
+	if r == nil {
+		return ""
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.Refreshed) == 0 {
+		return ""
+	}
+
+	refreshed := append([]RefreshedStanza(nil), r.Refreshed...)
+	sort.Slice(refreshed, func(i, j int) bool {
+		return refreshed[i].Name < refreshed[j].Name
+	})
+	var done, failed []string
+	for _, s := range refreshed {
+		if s.Error != "" {
+			failed = append(failed, fmt.Sprintf("%s (%s): %s", s.Name, s.Reason, s.Error))
+		} else {
+			done = append(done, fmt.Sprintf("%s (%s)", s.Name, s.Reason))
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d stanzas refreshed", len(done))
+	if len(done) > 0 {
+		fmt.Fprintf(&b, ": %s", strings.Join(done, ", "))
+	}
+	if len(failed) > 0 {
+		fmt.Fprintf(&b, "; %d refreshes failed, the previous stanza is kept: %s", len(failed), strings.Join(failed, ", "))
+	}
+	return b.String()
+}
+
+// WriteFile stores the report as JSON.
+func (r *Report) WriteFile(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.Failures == nil {
+		r.Failures = []DependencyFailure{}
+	}
+	if r.Ignored == nil {
+		r.Ignored = []IgnoredDependency{}
+	}
+	if r.MissingNotices == nil {
+		r.MissingNotices = []MissingNotice{}
+	}
+	if r.MissingLicenseTexts == nil {
+		r.MissingLicenseTexts = []MissingLicenseText{}
+	}
+	if r.Refreshed == nil {
+		r.Refreshed = []RefreshedStanza{}
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, append(data, '\n'), 0644)
+}
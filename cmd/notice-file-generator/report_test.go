@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReport(t *testing.T) {
+	report := &Report{}
+	assert.False(t, report.HasFailures())
+
+	report.AddFailure("react", JsDep, StageGenerate, errors.New("http status code 404"))
+	report.AddFailure("example.com/mod", GoDep, StageResolve, errors.New("no go-import meta tags"))
+	report.AddIgnored(IgnoredDependency{Name: "wix", Ecosystem: "unknown", Rule: "pattern wix"})
+	assert.True(t, report.HasFailures())
+	assert.Equal(t, "2 dependencies could not be processed and need a manual stanza in NOTICE.txt:\n"+
+		"  - example.com/mod (go) resolve: no go-import meta tags\n"+
+		"  - react (npm) generate: http status code 404\n", report.Summary())
+
+	name := filepath.Join(t.TempDir(), "report.json")
+	require.NoError(t, report.WriteFile(name))
+	data, err := os.ReadFile(name)
+	require.NoError(t, err)
+
+	var written Report
+	require.NoError(t, json.Unmarshal(data, &written))
+	assert.Equal(t, report.Failures, written.Failures)
+	assert.Equal(t, report.Ignored, written.Ignored)
+	assert.Equal(t, []MissingNotice{}, written.MissingNotices)
+}
+
+func TestMissingNoticeSummary(t *testing.T) {
+	report := &Report{}
+	assert.Equal(t, "", report.MissingNoticeSummary())
+
+	report.AddMissingNotice("github.com/spf13/cobra", GoDep)
+	report.AddMissingNotice("typescript", JsDep)
+	assert.False(t, report.HasFailures())
+	assert.Equal(t, "2 Apache-2.0 dependencies have no NOTICE file: github.com/spf13/cobra (go), typescript (npm)", report.MissingNoticeSummary())
+}
+
+func TestMissingLicenseTextSummary(t *testing.T) {
+	report := &Report{}
+	assert.Equal(t, "", report.MissingLicenseTextSummary())
+
+	report.AddMissingLicenseText("widget", ManualDep, "LicenseRef-Custom")
+	assert.False(t, report.HasFailures())
+	assert.Equal(t, "1 dependencies have no license text: widget (manual) LicenseRef-Custom", report.MissingLicenseTextSummary())
+}
+
+func TestNilReport(t *testing.T) {
+	var report *Report
+	report.AddFailure("react", JsDep, StageGenerate, errors.New("failed"))
+	assert.False(t, report.HasFailures())
+	assert.Equal(t, "", report.Summary())
+}
+
+func TestRefreshSummary(t *testing.T) {
+	report := &Report{}
+	assert.Equal(t, "", report.RefreshSummary())
+
+	report.AddRefreshed("react", JsDep, "version 18.2.0 to 18.3.1", nil)
+	report.AddRefreshed("lodash", JsDep, "requested", errors.New("http status code 503"))
+	report.AddRefreshed("cobra", GoDep, "generated 120 days ago", nil)
+	assert.Equal(t, "2 stanzas refreshed: cobra (generated 120 days ago), react (version 18.2.0 to 18.3.1); "+
+		"1 refreshes failed, the previous stanza is kept: lodash (requested): http status code 503", report.RefreshSummary())
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// LicenseExpression is a parsed SPDX license expression, ie.
+// "(MIT OR Apache-2.0) AND BSD-3-Clause". A leaf holds a license and its
+// exception, an AND or OR node holds its operands.
+type LicenseExpression struct {
+	Operator  string               `json:"operator,omitempty"`
+	Operands  []*LicenseExpression `json:"operands,omitempty"`
+	License   string               `json:"license,omitempty"`
+	Exception string               `json:"exception,omitempty"`
+}
+
+const (
+	operatorAnd = "AND"
+	operatorOr  = "OR"
+)
+
+// ParseLicenseExpression parses an SPDX license expression. Operators are
+// accepted in any case, as many npm packages write them in lower case.
+func ParseLicenseExpression(expression string) (*LicenseExpression, error) {
+	p := &expressionParser{tokens: tokenizeExpression(expression)}
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("empty license expression")
+	}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("invalid license expression %q: %w", expression, err)
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("invalid license expression %q: unexpected %q", expression, p.tokens[p.pos])
+	}
+	return e, nil
+}
+
+func tokenizeExpression(expression string) []string {
+	var tokens []string
+	for _, field := range strings.Fields(expression) {
+		for field != "" {
+			i := strings.IndexAny(field, "()")
+			switch {
+			case i < 0:
+				tokens = append(tokens, field)
+				field = ""
+			case i > 0:
+				tokens = append(tokens, field[:i])
+				field = field[i:]
+			default:
+				tokens = append(tokens, field[:1])
+				field = field[1:]
+			}
+		}
+	}
+	return tokens
+}
+
+type expressionParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *expressionParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return strings.ToUpper(p.tokens[p.pos])
+	}
+	return ""
+}
+
+// parseOr and parseAnd handle the operators by increasing precedence, WITH
+// being handled by parseLicense.
+func (p *expressionParser) parseOr() (*LicenseExpression, error) {
+	return p.parseOperator(operatorOr, p.parseAnd)
+}
+
+func (p *expressionParser) parseAnd() (*LicenseExpression, error) {
+	return p.parseOperator(operatorAnd, p.parseLicense)
+}
+
+func (p *expressionParser) parseOperator(operator string, operand func() (*LicenseExpression, error)) (*LicenseExpression, error) {
+	e, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != operator {
+		return e, nil
+	}
+	node := &LicenseExpression{Operator: operator}
+	node.add(e)
+	for p.peek() == operator {
+		p.pos++
+		e, err = operand()
+		if err != nil {
+			return nil, err
+		}
+		node.add(e)
+	}
+	return node, nil
+}
+
+// add appends an operand, flattening the nested nodes of the same operator.
+func (e *LicenseExpression) add(operand *LicenseExpression) {
+	if operand.Operator == e.Operator {
+		e.Operands = append(e.Operands, operand.Operands...)
+		return
+	}
+	e.Operands = append(e.Operands, operand)
+}
+
+func (p *expressionParser) parseLicense() (*LicenseExpression, error) {
+	switch token := p.peek(); token {
+	case "":
+		return nil, fmt.Errorf("unexpected end")
+	case "(":
+		p.pos++
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		p.pos++
+		return e, nil
+	case ")", operatorAnd, operatorOr, "WITH":
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+
+	e := &LicenseExpression{License: p.tokens[p.pos]}
+	p.pos++
+	if p.peek() == "WITH" {
+		p.pos++
+		switch p.peek() {
+		case "", "(", ")", operatorAnd, operatorOr, "WITH":
+			return nil, fmt.Errorf("missing exception after WITH")
+		}
+		e.Exception = p.tokens[p.pos]
+		p.pos++
+	}
+	return e, nil
+}
+
+func (e *LicenseExpression) String() string {
+	if e.Operator == "" {
+		if e.Exception != "" {
+			return e.License + " WITH " + e.Exception
+		}
+		return e.License
+	}
+	operands := make([]string, len(e.Operands))
+	for i, operand := range e.Operands {
+		operands[i] = operand.String()
+		// AND takes precedence over OR
+		if operand.Operator != "" {
+			operands[i] = "(" + operands[i] + ")"
+		}
+	}
+	return strings.Join(operands, " "+e.Operator+" ")
+}
+
+// Licenses returns the licenses of the expression, in order and without
+// duplicates.
+func (e *LicenseExpression) Licenses() []string {
+	if e == nil {
+		return nil
+	}
+	if e.Operator == "" {
+		return []string{e.License}
+	}
+	var licenses []string
+	seen := make(map[string]bool)
+	for _, operand := range e.Operands {
+		for _, license := range operand.Licenses() {
+			if !seen[license] {
+				seen[license] = true
+				licenses = append(licenses, license)
+			}
+		}
+	}
+	return licenses
+}
+
+// Elect resolves every OR of the expression to the branch whose licenses come
+// first in the preference list, licenses missing from the list coming last.
+// Ties are resolved in favour of the branch declared first.
+func (e *LicenseExpression) Elect(preference []string) *LicenseExpression {
+	switch e.Operator {
+	case "":
+		return e
+	case operatorOr:
+		var elected *LicenseExpression
+		best := 0
+		for _, operand := range e.Operands {
+			operand = operand.Elect(preference)
+			if rank := operand.rank(preference); elected == nil || rank < best {
+				elected, best = operand, rank
+			}
+		}
+		return elected
+	}
+	elected := &LicenseExpression{Operator: e.Operator}
+	for _, operand := range e.Operands {
+		elected.add(operand.Elect(preference))
+	}
+	return elected
+}
+
+// rank is the position in the preference list of the least preferred license
+// of an expression without OR.
+func (e *LicenseExpression) rank(preference []string) int {
+	worst := 0
+	for _, license := range e.Licenses() {
+		rank := len(preference)
+		for i, preferred := range preference {
+			if strings.EqualFold(CanonicalLicenseID(preferred), CanonicalLicenseID(license)) {
+				rank = i
+				break
+			}
+		}
+		worst = max(worst, rank)
+	}
+	return worst
+}
+
+// parseLicenseExpression parses the declared license of the dependency, when
+// it is an SPDX expression, and elects the licenses it is distributed under.
+// Names such as "Apache License 2.0" are not expressions, the identifier
+// returned by the code host is used instead.
+func (d *Dependency) parseLicenseExpression(preference []string) {
+	d.LicenseExpression, d.ElectedLicense = nil, nil
+	for _, license := range []string{d.License, d.LicenseID} {
+		if license == "" || license == "NOASSERTION" {
+			continue
+		}
+		if e, err := ParseLicenseExpression(license); err == nil {
+			d.LicenseExpression = e
+			d.ElectedLicense = e.Elect(preference)
+			return
+		}
+	}
+}
+
+// ApplicableLicenses returns the licenses the dependency is distributed under:
+// those of the elected expression, or the single declared license.
+func (d *Dependency) ApplicableLicenses() []string {
+	if d.ElectedLicense != nil {
+		return d.ElectedLicense.Licenses()
+	}
+	if d.LicenseID != "" && d.LicenseID != "NOASSERTION" {
+		return []string{d.LicenseID}
+	}
+	if d.License != "" {
+		return []string{d.License}
+	}
+	return nil
+}
+
+// LicenseLine returns the value of the LICENSE line of the stanza, naming the
+// elected licenses when the declared expression offers a choice.
+func (d *Dependency) LicenseLine() string {
+	if d.LicenseExpression == nil || d.LicenseExpression.Operator == "" {
+		return d.License
+	}
+	declared := d.LicenseExpression.String()
+	if elected := d.ElectedLicense.String(); elected != declared {
+		return fmt.Sprintf("%s (%s elected)", declared, elected)
+	}
+	return declared
+}
+
+// unmarshalNpmLicenses reads the legacy license fields of package.json: a
+// license object, or a licenses array meaning a choice between its entries.
+func (d *Dependency) unmarshalNpmLicenses(data []byte) error {
+	var legacy struct {
+		License  json.RawMessage   `json:"license"`
+		Licenses []json.RawMessage `json:"licenses"`
+	}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		// The licenses field is neither an array nor missing
+		return nil
+	}
+	if d.License == "" && len(legacy.License) > 0 {
+		d.License = npmLicenseType(legacy.License)
+	}
+	if d.License != "" || len(legacy.Licenses) == 0 {
+		return nil
+	}
+	var licenses []string
+	for _, raw := range legacy.Licenses {
+		if license := npmLicenseType(raw); license != "" {
+			licenses = append(licenses, license)
+		}
+	}
+	if len(licenses) == 1 {
+		d.License = licenses[0]
+	} else if len(licenses) > 1 {
+		d.License = "(" + strings.Join(licenses, " OR ") + ")"
+	}
+	return nil
+}
+
+// npmLicenseType returns the license of a string or {"type": ...} entry.
+func npmLicenseType(raw json.RawMessage) string {
+	var license string
+	if json.Unmarshal(raw, &license) == nil {
+		return strings.TrimSpace(license)
+	}
+	var entry struct {
+		Type string `json:"type"`
+	}
+	if json.Unmarshal(raw, &entry) == nil {
+		return strings.TrimSpace(entry.Type)
+	}
+	return ""
+}
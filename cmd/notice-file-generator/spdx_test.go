@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLicenseExpression(t *testing.T) {
+	e, err := ParseLicenseExpression("(MIT OR Apache-2.0) AND BSD-3-Clause")
+	require.NoError(t, err)
+	assert.Equal(t, &LicenseExpression{Operator: "AND", Operands: []*LicenseExpression{
+		{Operator: "OR", Operands: []*LicenseExpression{{License: "MIT"}, {License: "Apache-2.0"}}},
+		{License: "BSD-3-Clause"},
+	}}, e)
+	assert.Equal(t, "(MIT OR Apache-2.0) AND BSD-3-Clause", e.String())
+	assert.Equal(t, []string{"MIT", "Apache-2.0", "BSD-3-Clause"}, e.Licenses())
+
+	// AND takes precedence over OR, and operators are flattened
+	e, err = ParseLicenseExpression("MIT or Apache-2.0 AND Zlib OR (ISC OR MIT)")
+	require.NoError(t, err)
+	assert.Equal(t, "MIT OR (Apache-2.0 AND Zlib) OR ISC OR MIT", e.String())
+	assert.Equal(t, []string{"MIT", "Apache-2.0", "Zlib", "ISC"}, e.Licenses())
+
+	e, err = ParseLicenseExpression("GPL-2.0-or-later WITH Classpath-exception-2.0")
+	require.NoError(t, err)
+	assert.Equal(t, &LicenseExpression{License: "GPL-2.0-or-later", Exception: "Classpath-exception-2.0"}, e)
+
+	for _, invalid := range []string{"", "MIT OR", "(MIT", "MIT)", "MIT License", "AND MIT", "MIT WITH"} {
+		_, err = ParseLicenseExpression(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestElectLicense(t *testing.T) {
+	e, err := ParseLicenseExpression("(GPL-3.0-only OR MIT) AND (Apache-2.0 OR BSD-2-Clause)")
+	require.NoError(t, err)
+
+	// Without preference the first branch is elected
+	assert.Equal(t, "GPL-3.0-only AND Apache-2.0", e.Elect(nil).String())
+	assert.Equal(t, "MIT AND BSD-2-Clause", e.Elect([]string{"MIT", "BSD-2-Clause"}).String())
+	assert.Equal(t, "MIT AND Apache-2.0", e.Elect([]string{"Apache-2.0", "MIT"}).String())
+
+	// A branch is ranked by its least preferred license
+	e, err = ParseLicenseExpression("(MIT AND GPL-2.0) OR Apache-2.0")
+	require.NoError(t, err)
+	assert.Equal(t, "Apache-2.0", e.Elect([]string{"MIT", "Apache-2.0"}).String())
+}
+
+func TestDependencyLicenseExpression(t *testing.T) {
+	dep := Dependency{License: "MIT OR Apache-2.0"}
+	dep.parseLicenseExpression([]string{"Apache-2.0"})
+	assert.Equal(t, []string{"Apache-2.0"}, dep.ApplicableLicenses())
+	assert.Equal(t, "MIT OR Apache-2.0 (Apache-2.0 elected)", dep.LicenseLine())
+	assert.True(t, dep.IsApacheLicensed())
+
+	dep.parseLicenseExpression(nil)
+	assert.Equal(t, []string{"MIT"}, dep.ApplicableLicenses())
+	assert.False(t, dep.IsApacheLicensed())
+
+	// License names of code hosts fall back to their identifier
+	dep = Dependency{License: "Apache License 2.0", LicenseID: "Apache-2.0"}
+	dep.parseLicenseExpression(nil)
+	assert.Equal(t, &LicenseExpression{License: "Apache-2.0"}, dep.LicenseExpression)
+	assert.Equal(t, "Apache License 2.0", dep.LicenseLine())
+
+	dep = Dependency{License: "Other", LicenseID: "NOASSERTION"}
+	dep.parseLicenseExpression(nil)
+	assert.Equal(t, &LicenseExpression{License: "Other"}, dep.LicenseExpression)
+}
+
+func TestNpmLegacyLicenses(t *testing.T) {
+	var dep Dependency
+	require.NoError(t, dep.unmarshalNpm([]byte(`{"name": "legacy", "licenses": [{"type": "MIT", "url": "https://example.com/mit"}, {"type": "Apache-2.0"}]}`)))
+	assert.Equal(t, "(MIT OR Apache-2.0)", dep.License)
+
+	dep = Dependency{}
+	require.NoError(t, dep.unmarshalNpm([]byte(`{"name": "legacy", "licenses": ["BSD-3-Clause"]}`)))
+	assert.Equal(t, "BSD-3-Clause", dep.License)
+
+	dep = Dependency{}
+	require.NoError(t, dep.unmarshalNpm([]byte(`{"name": "legacy", "description": "Old package", "license": {"type": "ISC", "url": "https://example.com/isc"}}`)))
+	assert.Equal(t, "ISC", dep.License)
+	assert.Equal(t, "Old package", dep.Description)
+
+	// The license field takes precedence
+	dep = Dependency{}
+	require.NoError(t, dep.unmarshalNpm([]byte(`{"name": "modern", "license": "MIT", "licenses": [{"type": "GPL-2.0"}]}`)))
+	assert.Equal(t, "MIT", dep.License)
+}
+
+func TestGenerateLicenseExpression(t *testing.T) {
+	config := &Config{Path: t.TempDir(), Report: &Report{}, LicensePreference: []string{"Zlib"}}
+	require.NoError(t, CreateNoticeDir(config))
+
+	dep := Dependency{Name: "dual", License: "(MIT AND ISC) OR Apache-2.0", DependencyType: ManualDep}
+	require.NoError(t, dep.Generate(context.Background(), config))
+	stanza, err := os.ReadFile(filepath.Join(config.NoticeWorkPath(), dep.FileName()))
+	require.NoError(t, err)
+	assert.Contains(t, string(stanza), "* LICENSE: (MIT AND ISC) OR Apache-2.0 (MIT AND ISC elected)")
+	assert.Contains(t, string(stanza), "Permission is hereby granted, free of charge")
+	assert.Contains(t, string(stanza), "Permission to use, copy, modify, and")
+	assert.NotContains(t, string(stanza), "Apache License")
+
+	// The text of the repository is completed with the missing licenses only
+	dep = Dependency{Name: "both", License: "MIT AND ISC", LicenseText: mitLicense, DependencyType: ManualDep}
+	require.NoError(t, dep.Generate(context.Background(), config))
+	stanza, err = os.ReadFile(filepath.Join(config.NoticeWorkPath(), dep.FileName()))
+	require.NoError(t, err)
+	assert.Contains(t, string(stanza), mitLicense)
+	assert.Contains(t, string(stanza), "Permission to use, copy, modify, and")
+	assert.Contains(t, string(stanza), "* LICENSE TEXT: standard ISC text, not verified against upstream")
+	assert.NotContains(t, string(stanza), "Copyright (c) <year>")
+}
+
+func TestLicenseTextMissing(t *testing.T) {
+	assert.False(t, LicenseTextMissing(mitLicense, "MIT"))
+	assert.True(t, LicenseTextMissing(mitLicense, "Apache-2.0"))
+	assert.False(t, LicenseTextMissing(mitLicense, "LicenseRef-Custom"))
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/module"
+)
+
+// errNoHostProvider is returned by LoadFromHost for repositories on a host
+// without a supported API.
+var errNoHostProvider = errors.New("no code host API available")
+
+// vcsProtocols are the transports allowed to clone a repository. The URL
+// comes from the go-import meta tag of the dependency host, so transports
+// running commands, ie. git "ext::", are never allowed.
+var vcsProtocols = []string{"https", "http", "ssh", "git"}
+
+// readmeFiles are the README names read for a description, in order of
+// preference.
+var readmeFiles = []string{"README.md", "README", "README.txt", "README.rst", "readme.md"}
+
+// Revision returns the tag or commit of the repository holding the pinned
+// version of a Go module, or "" for the default branch.
+func (d *Dependency) Revision() string {
+	if d.DependencyType != GoDep || d.Version == "" {
+		return ""
+	}
+	if module.IsPseudoVersion(d.Version) {
+		rev, err := module.PseudoVersionRev(d.Version)
+		if err != nil {
+			return ""
+		}
+		return rev
+	}
+	tag := strings.TrimSuffix(d.Version, "+incompatible")
+	// Modules in a subdirectory are tagged with the directory as prefix,
+	// without the major version suffix
+	if dirs := d.ModuleDirs(); len(dirs) > 0 {
+		dir := dirs[len(dirs)-1]
+		if regexpMajorVersion.MatchString(path.Base(dir)) {
+			dir = path.Dir(dir)
+		}
+		if dir != "." {
+			tag = dir + "/" + tag
+		}
+	}
+	return tag
+}
+
+// LoadFromClone fetches the repository of the dependency with its version
+// control tool, then reads the license, copyrights and description from the
+// checked out files. It is used for hosts without a supported API.
+func (d *Dependency) LoadFromClone(ctx context.Context, config *Config) error {
+	vcs := d.Repository.Type
+	switch vcs {
+	case "git", "hg":
+	default:
+		log.Printf("Cannot inspect %s, unsupported version control system %q", d.Name, vcs)
+		return fmt.Errorf("cannot read the license from %s: unsupported version control system %q", d.Repository.URL, vcs)
+	}
+	if config.Offline {
+		return fmt.Errorf("%w: cannot clone %s", errOffline, d.Repository.URL)
+	}
+	if err := checkCloneURL(d.Repository.URL); err != nil {
+		return err
+	}
+	if _, err := exec.LookPath(vcs); err != nil {
+		log.Printf("Cannot inspect %s, %s is not installed", d.Name, vcs)
+		return fmt.Errorf("cannot read the license from %s: %s is not installed", d.Repository.URL, vcs)
+	}
+
+	dir, err := os.MkdirTemp("", "notice-clone-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	checkout := filepath.Join(dir, "repository")
+
+	log.Printf("Cloning %s to inspect %s", d.Repository.URL, d.Name)
+	if vcs == "git" {
+		err = cloneGit(ctx, d.Repository.URL, d.Revision(), checkout)
+	} else {
+		err = cloneHg(ctx, d.Repository.URL, d.Revision(), checkout)
+	}
+	if err != nil {
+		return err
+	}
+	d.inspectCheckout(checkout)
+	return nil
+}
+
+// checkCloneURL rejects the repository URLs which would be read as an option
+// of the version control tool, or use a transport outside of vcsProtocols.
+func checkCloneURL(repoURL string) error {
+	if repoURL == "" || strings.HasPrefix(repoURL, "-") {
+		return fmt.Errorf("invalid repository URL %q", repoURL)
+	}
+	protocol := "file"
+	if i := strings.Index(repoURL, "://"); i > 0 {
+		protocol = strings.ToLower(repoURL[:i])
+	} else if i := strings.Index(repoURL, "::"); i > 0 {
+		protocol = strings.ToLower(repoURL[:i])
+	} else if i := strings.IndexAny(repoURL, ":/"); i > 0 && repoURL[i] == ':' {
+		// The scp-like syntax, ie. "git@example.com:org/repo"
+		protocol = "ssh"
+	}
+	for _, p := range vcsProtocols {
+		if protocol == p {
+			return nil
+		}
+	}
+	return fmt.Errorf("repository URL %q uses the disallowed protocol %q", repoURL, protocol)
+}
+
+// cloneGit checks out a revision with as little history as possible: a
+// shallow clone for a tag or the default branch, a blobless one for a commit.
+func cloneGit(ctx context.Context, repoURL, rev, dir string) error {
+	if rev != "" && !isCommitHash(rev) {
+		if err := runVCS(ctx, "git", "clone", "-q", "--depth", "1", "--branch", rev, "--", repoURL, dir); err == nil {
+			return nil
+		}
+		log.Printf("Tag %s not found in %s, using the default branch", rev, repoURL)
+		os.RemoveAll(dir)
+		rev = ""
+	}
+	if rev == "" {
+		return runVCS(ctx, "git", "clone", "-q", "--depth", "1", "--", repoURL, dir)
+	}
+	if err := runVCS(ctx, "git", "clone", "-q", "--filter=blob:none", "--no-checkout", "--", repoURL, dir); err != nil {
+		return err
+	}
+	return runVCS(ctx, "git", "-C", dir, "checkout", "-q", rev)
+}
+
+func cloneHg(ctx context.Context, repoURL, rev, dir string) error {
+	if rev != "" {
+		if err := runVCS(ctx, "hg", "clone", "-q", "-u", rev, "--", repoURL, dir); err == nil {
+			return nil
+		}
+		log.Printf("Revision %s not found in %s, using the default branch", rev, repoURL)
+		os.RemoveAll(dir)
+	}
+	return runVCS(ctx, "hg", "clone", "-q", "--", repoURL, dir)
+}
+
+func runVCS(ctx context.Context, name string, args ...string) error {
+	if name == "hg" {
+		// Mercurial has no list of allowed transports, the schemes extension
+		// would map custom ones to any URL
+		args = append([]string{"--config", "extensions.schemes=!"}, args...)
+	}
+	cmd := exec.CommandContext(ctx, name, args...)
+	// Never wait for credentials on a terminal
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "HGPLAIN=1", "GIT_ALLOW_PROTOCOL="+strings.Join(vcsProtocols, ":"))
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s: %w: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func isCommitHash(rev string) bool {
+	if len(rev) < 7 || len(rev) > 40 {
+		return false
+	}
+	for _, r := range rev {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// inspectCheckout reads the license and NOTICE files of the module directory,
+// or of the root, and derives the missing metadata from them and the README.
+func (d *Dependency) inspectCheckout(checkout string) {
+	text := ""
+	for _, dir := range append(d.ModuleDirs(), "") {
+		if t, err := readLicenseFiles(filepath.Join(checkout, filepath.FromSlash(dir))); err == nil && t != "" {
+			text = t
+			break
+		}
+	}
+	for _, dir := range append(d.ModuleDirs(), "") {
+		if notice := readNoticeFile(filepath.Join(checkout, filepath.FromSlash(dir))); notice != "" {
+			d.NoticeText = notice
+			break
+		}
+	}
+	if text != "" {
+		d.LicenseText = text
+		if d.License == "" {
+			d.License = IdentifyLicense(text)
+		}
+	}
+	// The sources of the module directory, not of the whole repository
+	sources := checkout
+	if dirs := d.ModuleDirs(); len(dirs) > 0 {
+		sources = filepath.Join(checkout, filepath.FromSlash(dirs[0]))
+	}
+	d.loadCopyrights(sources)
+	if d.Description == "" {
+		d.Description = readmeDescription(checkout)
+	}
+	if d.HomePage == "" {
+		d.HomePage = strings.TrimSuffix(d.Repository.URL, ".git")
+	}
+}
+
+// readmeDescription returns the first paragraph of prose of the README,
+// skipping titles, badges and HTML.
+func readmeDescription(dir string) string {
+	for _, name := range readmeFiles {
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		defer f.Close()
+
+		var paragraph []string
+		inCode := false
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if strings.HasPrefix(line, "```") {
+				inCode = !inCode
+				continue
+			}
+			switch {
+			case inCode:
+			case line == "":
+				if len(paragraph) > 0 {
+					return strings.Join(paragraph, " ")
+				}
+			case strings.Trim(line, "=-") == "":
+				// The underline of a title, or a rule
+				paragraph = nil
+			case strings.HasPrefix(line, "#"), strings.HasPrefix(line, "!["), strings.HasPrefix(line, "[!["),
+				strings.HasPrefix(line, "<"):
+				if len(paragraph) > 0 {
+					return strings.Join(paragraph, " ")
+				}
+			default:
+				paragraph = append(paragraph, line)
+			}
+		}
+		return strings.Join(paragraph, " ")
+	}
+	return ""
+}
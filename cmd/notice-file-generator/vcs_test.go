@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func git(t *testing.T, dir string, args ...string) string {
+	cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=Test", "-c", "user.email=test@example.com", "-c", "init.defaultBranch=main"}, args...)...)
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+	return strings.TrimSpace(string(out))
+}
+
+// newGitRepository creates a repository with a tagged release and a later
+// commit changing the license, cloned through the file protocol.
+func newGitRepository(t *testing.T) (string, string) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	protocols := vcsProtocols
+	vcsProtocols = append([]string{"file"}, protocols...)
+	t.Cleanup(func() { vcsProtocols = protocols })
+	dir := t.TempDir()
+	git(t, dir, "init", "-q")
+	writeTestFile(t, filepath.Join(dir, "README.md"), "Widget\n======\n\n[![Build](https://example.com/badge.svg)](https://example.com)\n\nWidget renders widgets\nfor the terminal.\n\n## Usage\n")
+	writeTestFile(t, filepath.Join(dir, "LICENSE"), "Copyright (c) 2019-2021 Jane Doe\n\n"+mitLicense)
+	writeTestFile(t, filepath.Join(dir, "sub", "COPYING"), "Sub module license")
+	writeTestFile(t, filepath.Join(dir, "NOTICE"), "Widget\nCopyright 2019 Jane Doe")
+	git(t, dir, "add", "-A")
+	git(t, dir, "commit", "-q", "-m", "Release")
+	git(t, dir, "tag", "v1.2.0")
+	git(t, dir, "tag", "sub/v1.0.0")
+	release := git(t, dir, "rev-parse", "HEAD")
+
+	writeTestFile(t, filepath.Join(dir, "LICENSE"), "Relicensed")
+	git(t, dir, "commit", "-q", "-am", "Relicense")
+	return dir, release
+}
+
+func TestRevision(t *testing.T) {
+	dep := Dependency{FullName: "example.com/repo", Version: "v1.2.0", Repository: DependencyRepository{URL: "https://example.com/repo"}, DependencyType: GoDep}
+	assert.Equal(t, "v1.2.0", dep.Revision())
+
+	dep.Version = "v2.0.0+incompatible"
+	assert.Equal(t, "v2.0.0", dep.Revision())
+
+	dep.Version = "v0.0.0-20240102030405-abcdef123456"
+	assert.Equal(t, "abcdef123456", dep.Revision())
+
+	dep = Dependency{FullName: "example.com/org/repo/sub/v2", Version: "v2.1.0", Repository: DependencyRepository{URL: "https://example.com/org/repo"}, DependencyType: GoDep}
+	assert.Equal(t, "sub/v2.1.0", dep.Revision())
+
+	dep = Dependency{Name: "react", Version: "^18.0.0", DependencyType: JsDep}
+	assert.Equal(t, "", dep.Revision())
+}
+
+func TestLoadFromCloneTag(t *testing.T) {
+	dir, _ := newGitRepository(t)
+	dep := Dependency{Name: "repo", FullName: "example.com/repo", Version: "v1.2.0", Repository: DependencyRepository{Type: "git", URL: "file://" + dir}, DependencyType: GoDep}
+
+	require.NoError(t, dep.LoadFromClone(context.Background(), &Config{}))
+	assert.Equal(t, "MIT", dep.License)
+	assert.Contains(t, dep.LicenseText, "Permission is hereby granted")
+	assert.Equal(t, []Copyright{{Years: "2019-2021", Holder: "Jane Doe"}}, dep.Copyrights)
+	assert.Equal(t, "Widget renders widgets for the terminal.", dep.Description)
+	assert.Equal(t, "Widget\nCopyright 2019 Jane Doe", dep.NoticeText)
+	assert.Equal(t, "file://"+dir, dep.HomePage)
+}
+
+func TestLoadFromCloneCommit(t *testing.T) {
+	dir, release := newGitRepository(t)
+	dep := Dependency{Name: "repo", FullName: "example.com/repo", Version: "v0.0.0-20240102030405-" + release[:12], Repository: DependencyRepository{Type: "git", URL: "file://" + dir}, DependencyType: GoDep}
+
+	require.NoError(t, dep.LoadFromClone(context.Background(), &Config{}))
+	assert.Contains(t, dep.LicenseText, "Permission is hereby granted")
+
+	// A missing tag falls back to the default branch
+	dep = Dependency{Name: "repo", FullName: "example.com/repo", Version: "v9.9.9", Repository: DependencyRepository{Type: "git", URL: "file://" + dir}, DependencyType: GoDep}
+	require.NoError(t, dep.LoadFromClone(context.Background(), &Config{}))
+	assert.Equal(t, "Relicensed", dep.LicenseText)
+}
+
+func TestLoadFromCloneSubdirectory(t *testing.T) {
+	dir, _ := newGitRepository(t)
+	repoURL := "file://" + dir
+	root, _ := RepositoryRoot(repoURL)
+	dep := Dependency{Name: "repo/sub", Version: "v1.0.0", Repository: DependencyRepository{Type: "git", URL: repoURL}, DependencyType: GoDep}
+	// Local paths are not import paths, derive the module path the same way
+	dep.FullName = root + "/sub"
+
+	require.NoError(t, dep.LoadFromClone(context.Background(), &Config{}))
+	assert.Equal(t, "Sub module license", dep.LicenseText)
+}
+
+func TestCheckCloneURL(t *testing.T) {
+	assert.NoError(t, checkCloneURL("https://example.com/repo"))
+	assert.NoError(t, checkCloneURL("ssh://git@example.com/repo"))
+	assert.NoError(t, checkCloneURL("git@example.com:org/repo"))
+	assert.Error(t, checkCloneURL("--upload-pack=touch /tmp/pwned"))
+	assert.Error(t, checkCloneURL("ext::sh -c touch% /tmp/pwned"))
+	assert.Error(t, checkCloneURL("file:///etc"))
+	assert.Error(t, checkCloneURL("/etc"))
+	assert.Error(t, checkCloneURL(""))
+}
+
+func TestLoadFromCloneUnsupported(t *testing.T) {
+	config := &Config{Path: t.TempDir(), Report: &Report{}}
+	require.NoError(t, CreateNoticeDir(config))
+	dep := Dependency{Name: "repo", Repository: DependencyRepository{Type: "svn", URL: "https://example.com/svn/repo"}, DependencyType: GoDep}
+	err := dep.LoadFromClone(context.Background(), config)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unsupported version control system "svn"`)
+
+	// No stanza contradicts the failure
+	assert.Error(t, dep.Generate(context.Background(), config))
+	assert.Equal(t, "", dep.Load(config))
+
+	// Only the allowed transports are cloned
+	dep = Dependency{Name: "repo", Repository: DependencyRepository{Type: "git", URL: "ext::sh -c touch% /tmp/pwned"}, DependencyType: GoDep}
+	assert.Error(t, dep.LoadFromClone(context.Background(), config))
+}
+
+func TestGenerateFallsBackToClone(t *testing.T) {
+	dir, _ := newGitRepository(t)
+	config := &Config{Path: t.TempDir(), Report: &Report{}}
+	require.NoError(t, CreateNoticeDir(config))
+
+	dep := Dependency{Name: "repo", FullName: "example.com/repo", Version: "v1.2.0", Repository: DependencyRepository{Type: "git", URL: "file://" + dir}, DependencyType: GoDep}
+	require.NoError(t, dep.Generate(context.Background(), config))
+	stanza := dep.Load(config)
+	assert.Contains(t, stanza, "This product contains 'repo' by Jane Doe.")
+	assert.Contains(t, stanza, "* LICENSE: MIT")
+
+	dep = Dependency{Name: "missing", Repository: DependencyRepository{Type: "git", URL: "file://" + filepath.Join(dir, "missing")}, DependencyType: GoDep}
+	assert.Error(t, dep.Generate(context.Background(), config))
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// defaultVendoredDirectories are the directory names scanned for third-party
+// code when the configuration does not list any.
+var defaultVendoredDirectories = []string{"vendor", "third_party", "third-party", "thirdparty", "external"}
+
+// skippedDirectories are never scanned for vendored code.
+var skippedDirectories = []string{".git", "node_modules", ".notice", ".notice-work"}
+
+type GitSubmodule struct {
+	Name string
+	Path string
+	URL  string
+}
+
+func parseGitmodules(data []byte) []GitSubmodule {
+	var submodules []GitSubmodule
+	var current *GitSubmodule
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			current = nil
+			section := strings.Trim(line, "[]")
+			if name, ok := strings.CutPrefix(section, "submodule "); ok {
+				submodules = append(submodules, GitSubmodule{Name: strings.Trim(name, `"`)})
+				current = &submodules[len(submodules)-1]
+			}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		key, value, _ := strings.Cut(line, "=")
+		switch strings.TrimSpace(key) {
+		case "path":
+			current.Path = strings.TrimSpace(value)
+		case "url":
+			current.URL = strings.TrimSpace(value)
+		}
+	}
+	return submodules
+}
+
+func (c *Config) vendoredDirectories() []string {
+	if len(c.VendoredDirectories) > 0 {
+		return c.VendoredDirectories
+	}
+	return defaultVendoredDirectories
+}
+
+// readLicenseFiles returns the concatenated license files found directly in dir.
+func readLicenseFiles(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && IsLicenseFile(e.Name()) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	var texts []string
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return "", err
+		}
+		texts = append(texts, strings.TrimSpace(string(data)))
+	}
+	return strings.Join(texts, "\n\n"), nil
+}
+
+// readNoticeFile returns the NOTICE file found directly in dir, if any.
+func readNoticeFile(dir string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, e := range entries {
+		if !e.IsDir() && IsNoticeFile(e.Name()) {
+			data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+			if err == nil {
+				return strings.TrimSpace(string(data))
+			}
+		}
+	}
+	return ""
+}
+
+func (c *Config) vendoredDependency(name, dir string) (Dependency, error) {
+	location, err := filepath.Rel(c.Path, dir)
+	if err != nil {
+		return Dependency{}, err
+	}
+	text, err := readLicenseFiles(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return Dependency{}, err
+	}
+	d := Dependency{
+		Name:           name,
+		Description:    fmt.Sprintf("Third-party code embedded in %s", filepath.ToSlash(location)),
+		License:        IdentifyLicense(text),
+		LicenseText:    text,
+		NoticeText:     readNoticeFile(dir),
+		Location:       filepath.ToSlash(location),
+		DependencyType: VendoredDep,
+	}
+	d.loadCopyrights(dir)
+	return d, nil
+}
+
+// scanVendoredDirectory returns a dependency for every directory below root
+// holding a license file. Directories nested in such a component belong to it
+// and are not reported separately.
+func (c *Config) scanVendoredDirectory(root string, excluded map[string]bool) ([]Dependency, error) {
+	// Go module vendoring is already covered by go.mod
+	if _, err := os.Stat(filepath.Join(root, "modules.txt")); err == nil {
+		log.Printf("Skipping Go module vendor directory %s", root)
+		return nil, nil
+	}
+
+	var deps []Dependency
+	err := filepath.WalkDir(root, func(p string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !e.IsDir() {
+			return nil
+		}
+		if excluded[p] || IndexOf(skippedDirectories, e.Name()) >= 0 {
+			return filepath.SkipDir
+		}
+		text, err := readLicenseFiles(p)
+		if err != nil || text == "" {
+			return err
+		}
+		dep, err := c.vendoredDependency(filepath.Base(p), p)
+		if err != nil {
+			return err
+		}
+		log.Printf("Found vendored %s in %s", dep.Name, dep.Location)
+		deps = append(deps, dep)
+		return filepath.SkipDir
+	})
+	return deps, err
+}
+
+// PopulateVendoredDependencies finds third-party code that is embedded in the
+// repository as git submodules or copied into vendor style directories.
+func (c *Config) PopulateVendoredDependencies() ([]Dependency, error) {
+	var deps []Dependency
+	submodulePaths := make(map[string]bool)
+
+	if data, err := os.ReadFile(filepath.Join(c.Path, ".gitmodules")); err == nil {
+		for _, submodule := range parseGitmodules(data) {
+			if submodule.Path == "" {
+				continue
+			}
+			dir := filepath.Join(c.Path, filepath.FromSlash(submodule.Path))
+			submodulePaths[dir] = true
+			dep, err := c.vendoredDependency(filepath.Base(submodule.Path), dir)
+			if err != nil {
+				return nil, err
+			}
+			dep.Description = fmt.Sprintf("Git submodule embedded in %s", dep.Location)
+			if submodule.URL != "" {
+				dep.Repository = DependencyRepository{Type: "git", URL: submodule.URL}
+				if strings.HasPrefix(submodule.URL, "https://") {
+					dep.HomePage = strings.TrimSuffix(submodule.URL, ".git")
+				}
+			}
+			log.Printf("Found git submodule %s in %s", dep.Name, dep.Location)
+			deps = append(deps, dep)
+		}
+	}
+
+	vendoredDirectories := c.vendoredDirectories()
+	err := filepath.WalkDir(c.Path, func(p string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !e.IsDir() || p == c.Path {
+			return nil
+		}
+		if submodulePaths[p] || IndexOf(skippedDirectories, e.Name()) >= 0 {
+			return filepath.SkipDir
+		}
+		if IndexOf(vendoredDirectories, e.Name()) < 0 {
+			return nil
+		}
+		d, err := c.scanVendoredDirectory(p, submodulePaths)
+		if err != nil {
+			return err
+		}
+		deps = append(deps, d...)
+		return filepath.SkipDir
+	})
+	return deps, err
+}
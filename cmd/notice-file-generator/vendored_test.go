@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const mitLicense = "Copyright (c) 2020 Jane Doe\n\nPermission is hereby granted, free of charge, to any person obtaining a copy\n"
+
+func writeTestFile(t *testing.T, name, content string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(name), 0755))
+	require.NoError(t, os.WriteFile(name, []byte(content), 0644))
+}
+
+func TestParseGitmodules(t *testing.T) {
+	submodules := parseGitmodules([]byte(`[submodule "libs/zlib"]
+	path = libs/zlib
+	url = https://github.com/madler/zlib.git
+[core]
+	path = ignored
+[submodule "fonts"]
+	url = git@github.com:org/fonts.git
+	path = assets/fonts
+`))
+	assert.Equal(t, []GitSubmodule{
+		{Name: "libs/zlib", Path: "libs/zlib", URL: "https://github.com/madler/zlib.git"},
+		{Name: "fonts", Path: "assets/fonts", URL: "git@github.com:org/fonts.git"},
+	}, submodules)
+}
+
+func TestPopulateVendoredDependencies(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, ".gitmodules"), "[submodule \"zlib\"]\n\tpath = libs/zlib\n\turl = https://github.com/madler/zlib.git\n")
+	writeTestFile(t, filepath.Join(root, "libs/zlib/LICENSE"), "zlib License\n\nThis software is provided 'as-is', without any express or implied\nwarranty.\n3. Altered source versions must be plainly marked as such")
+	writeTestFile(t, filepath.Join(root, "third_party/lodash/LICENSE.txt"), mitLicense)
+	writeTestFile(t, filepath.Join(root, "third_party/lodash/sub/LICENSE"), "nested component is part of lodash")
+	writeTestFile(t, filepath.Join(root, "webapp/third_party/inter/OFL.txt"), "SIL Open Font License, Version 1.1")
+	writeTestFile(t, filepath.Join(root, "webapp/third_party/unlicensed/file.js"), "")
+	writeTestFile(t, filepath.Join(root, "vendor/modules.txt"), "# github.com/foo/bar v1.0.0\n")
+	writeTestFile(t, filepath.Join(root, "vendor/github.com/foo/bar/LICENSE"), mitLicense)
+	writeTestFile(t, filepath.Join(root, "node_modules/third_party/x/LICENSE"), mitLicense)
+
+	config := Config{Path: root}
+	deps, err := config.PopulateVendoredDependencies()
+	require.NoError(t, err)
+	sort.Slice(deps, func(i, j int) bool { return deps[i].Name < deps[j].Name })
+
+	require.Len(t, deps, 3)
+	assert.Equal(t, "inter", deps[0].Name)
+	assert.Equal(t, "webapp/third_party/inter", deps[0].Location)
+	assert.Equal(t, "OFL-1.1", deps[0].License)
+
+	assert.Equal(t, "lodash", deps[1].Name)
+	assert.Equal(t, "third_party/lodash", deps[1].Location)
+	assert.Equal(t, "MIT", deps[1].License)
+	assert.Equal(t, VendoredDep, deps[1].DependencyType)
+	assert.Equal(t, "Copyright (c) 2020 Jane Doe\n\nPermission is hereby granted, free of charge, to any person obtaining a copy", deps[1].LicenseText)
+
+	assert.Equal(t, "zlib", deps[2].Name)
+	assert.Equal(t, "libs/zlib", deps[2].Location)
+	assert.Equal(t, "Zlib", deps[2].License)
+	assert.Equal(t, "https://github.com/madler/zlib", deps[2].HomePage)
+	assert.Equal(t, "https://github.com/madler/zlib.git", deps[2].Repository.URL)
+}
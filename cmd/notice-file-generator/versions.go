@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// GroupVersions merges the dependencies declared several times, ie. in
+// several manifests or container images, into one entry listing all their
+// versions. The entry keeps the metadata of its first declaration and the
+// highest version, which is the one whose license is fetched.
+func GroupVersions(deps []Dependency) []Dependency {
+	var grouped []Dependency
+	index := make(map[string]int)
+	for _, d := range deps {
+		key := d.Identity()
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(grouped)
+			d.Versions = nil
+			d.AddVersion(d.Version)
+			grouped = append(grouped, d)
+			continue
+		}
+		grouped[i].AddVersion(d.Version)
+	}
+	return grouped
+}
+
+// AddVersion records a version of the dependency, keeping Versions sorted and
+// Version on the highest one.
+func (d *Dependency) AddVersion(version string) {
+	if version == "" {
+		return
+	}
+	for _, v := range d.Versions {
+		if v == version {
+			return
+		}
+	}
+	d.Versions = append(d.Versions, version)
+	sort.SliceStable(d.Versions, func(i, j int) bool {
+		return compareVersions(d.Versions[i], d.Versions[j]) < 0
+	})
+	d.Version = d.Versions[len(d.Versions)-1]
+}
+
+// compareVersions compares semantic versions, with or without the v prefix
+// and npm range operators, and falls back to the string order otherwise.
+func compareVersions(a, b string) int {
+	sa, sb := semverOf(a), semverOf(b)
+	if semver.IsValid(sa) && semver.IsValid(sb) {
+		if c := semver.Compare(sa, sb); c != 0 {
+			return c
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+func semverOf(version string) string {
+	version = strings.TrimLeft(strings.TrimSpace(version), "^~=<> v")
+	return "v" + strings.TrimSuffix(version, "+incompatible")
+}
+
+// VersionLine returns the VERSION line of the stanza, listing every version
+// when the package is included several times.
+func (d *Dependency) VersionLine() string {
+	switch len(d.Versions) {
+	case 0:
+		if d.Version == "" {
+			return ""
+		}
+		return "* VERSION: " + d.Version
+	case 1:
+		return "* VERSION: " + d.Versions[0]
+	}
+	return "* VERSIONS: " + strings.Join(d.Versions, ", ")
+}
+
+// npmLockedVersions returns the installed version of the packages from the
+// package-lock.json next to a package.json, which is more precise than the
+// range it declares. Both the node_modules layout of lockfile v2 and v3 and
+// the dependencies tree of lockfile v1 are read.
+func npmLockedVersions(packageJSON string) map[string]string {
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(packageJSON), "package-lock.json"))
+	if err != nil {
+		return nil
+	}
+	var lock struct {
+		Packages map[string]struct {
+			Version string `json:"version"`
+		} `json:"packages"`
+		Dependencies map[string]struct {
+			Version string `json:"version"`
+		} `json:"dependencies"`
+	}
+	if err = json.Unmarshal(data, &lock); err != nil {
+		return nil
+	}
+	versions := make(map[string]string)
+	for name, d := range lock.Dependencies {
+		versions[name] = d.Version
+	}
+	for path, p := range lock.Packages {
+		// Only the direct installs, not the nested node_modules
+		name, ok := strings.CutPrefix(path, "node_modules/")
+		if ok && !strings.Contains(name, "/node_modules/") && p.Version != "" {
+			versions[name] = p.Version
+		}
+	}
+	return versions
+}
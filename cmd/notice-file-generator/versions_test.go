@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupVersions(t *testing.T) {
+	deps := GroupVersions([]Dependency{
+		{Name: "pkg/errors", FullName: "github.com/pkg/errors", Version: "v0.9.1", Manifest: "server/go.mod", DependencyType: GoDep},
+		{Name: "lodash", Version: "4.17.21", DependencyType: JsDep},
+		{Name: "pkg/errors", FullName: "github.com/pkg/errors", Version: "v0.10.0", Manifest: "tools/go.mod", DependencyType: GoDep},
+		{Name: "pkg/errors", FullName: "github.com/pkg/errors", Version: "v0.9.1", DependencyType: GoDep},
+		{Name: "pkg/errors", FullName: "gitlab.com/pkg/errors", Version: "v1.0.0", DependencyType: GoDep},
+		{Name: "lodash", Version: "4.17.21", DependencyType: JsDep},
+		{Name: "debian", Version: "bookworm", DependencyType: ContainerDep},
+		{Name: "debian", Version: "bullseye", DependencyType: ContainerDep},
+	})
+
+	require.Len(t, deps, 4)
+	assert.Equal(t, []string{"v0.9.1", "v0.10.0"}, deps[0].Versions)
+	assert.Equal(t, "v0.10.0", deps[0].Version)
+	assert.Equal(t, "server/go.mod", deps[0].Manifest)
+	assert.Equal(t, []string{"4.17.21"}, deps[1].Versions)
+	assert.Equal(t, "gitlab.com/pkg/errors", deps[2].FullName)
+	assert.Equal(t, []string{"bookworm", "bullseye"}, deps[3].Versions)
+}
+
+func TestCompareVersions(t *testing.T) {
+	assert.Negative(t, compareVersions("v1.2.3", "v1.10.0"))
+	assert.Negative(t, compareVersions("^1.2.3", "1.10.0"))
+	assert.Positive(t, compareVersions("v2.0.0+incompatible", "v1.9.9"))
+	assert.Negative(t, compareVersions("1:2.3-1", "1:2.4-1"))
+	assert.Zero(t, compareVersions("1.21", "1.21"))
+}
+
+func TestVersionLine(t *testing.T) {
+	assert.Equal(t, "", (&Dependency{}).VersionLine())
+	assert.Equal(t, "* VERSION: v1.2.3", (&Dependency{Version: "v1.2.3"}).VersionLine())
+
+	d := &Dependency{}
+	d.AddVersion("v1.10.0")
+	d.AddVersion("v1.2.3")
+	assert.Equal(t, "* VERSIONS: v1.2.3, v1.10.0", d.VersionLine())
+}
+
+func TestPopulateJSDependenciesLockedVersions(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "package.json"), `{"dependencies": {"react": "^18.2.0", "left-pad": "~1.3.0"}}`)
+	writeTestFile(t, filepath.Join(root, "package-lock.json"), `{
+  "lockfileVersion": 3,
+  "packages": {
+    "": {"dependencies": {"react": "^18.2.0"}},
+    "node_modules/react": {"version": "18.3.1"},
+    "node_modules/other/node_modules/react": {"version": "16.14.0"}
+  }
+}`)
+
+	deps, err := (&Config{}).PopulateJSDependencies(filepath.Join(root, "package.json"))
+	require.NoError(t, err)
+	versions := make(map[string]string)
+	for _, d := range deps {
+		versions[d.Name] = d.Version
+	}
+	assert.Equal(t, map[string]string{"react": "18.3.1", "left-pad": "~1.3.0"}, versions)
+
+	writeTestFile(t, filepath.Join(root, "package-lock.json"), `{"lockfileVersion": 1, "dependencies": {"left-pad": {"version": "1.3.0"}}}`)
+	assert.Equal(t, map[string]string{"left-pad": "1.3.0"}, npmLockedVersions(filepath.Join(root, "package.json")))
+}
+
+func TestGenerateShowVersions(t *testing.T) {
+	config := &Config{Path: t.TempDir(), Report: &Report{}}
+	require.NoError(t, CreateNoticeDir(config))
+
+	dep := Dependency{Name: "widget", License: "MIT", LicenseText: "MIT License", HomePage: "https://example.com/widget", DependencyType: ManualDep}
+	dep.AddVersion("1.0.0")
+	dep.AddVersion("2.0.0")
+	require.NoError(t, dep.Generate(context.Background(), config))
+	stanza, err := os.ReadFile(filepath.Join(config.NoticeWorkPath(), dep.FileName()))
+	require.NoError(t, err)
+	assert.NotContains(t, string(stanza), "VERSION")
+
+	config.ShowVersions = true
+	require.NoError(t, dep.Generate(context.Background(), config))
+	stanza, err = os.ReadFile(filepath.Join(config.NoticeWorkPath(), dep.FileName()))
+	require.NoError(t, err)
+	assert.Contains(t, string(stanza), "* VERSIONS: 1.0.0, 2.0.0\n\n* HOMEPAGE:")
+}